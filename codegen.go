@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// initialisms are the words that are written in upper case in Go names
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "ip": "IP", "uuid": "UUID"}
+
+// listTables returns the tables of the current schema, without the migration tracking table
+func listTables() ([]string, error) {
+	var tables []string
+
+	rows, err := db.Query(`SELECT table_name
+FROM information_schema.tables
+WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' AND table_name <> 'schema_migrations'
+ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// generateModels introspects the given tables, or all tables if none is given,
+// and writes a model file for each of them into dir, named model_<table>.go
+//
+// The files belong to the package of dir, or to a new package named after dir.
+// A struct is not written if its type, or the same name in another case such as
+// Membershiptype for MembershipType, is already declared in that package.
+//
+// It returns the paths of the files that were written.
+func generateModels(dir string, tables ...string) ([]string, error) {
+	var written []string
+
+	if len(tables) == 0 {
+		all, err := listTables()
+		if err != nil {
+			return nil, err
+		}
+		tables = all
+	}
+
+	// The generated files of these tables are overwritten, so their types are not taken as declared
+	overwritten := make(map[string]bool)
+	for _, tableName := range tables {
+		overwritten["model_"+strings.ToLower(tableName)+".go"] = true
+	}
+
+	pkgName, declared, err := loadPackage(dir, overwritten)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tableName := range tables {
+		columns, err := loadColumns(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("table %q does not exist", tableName)
+		}
+
+		structName := goName(singular(strings.ToLower(tableName)))
+		for _, name := range declared {
+			if strings.EqualFold(name, structName) {
+				return written, fmt.Errorf("the model of table %q would redeclare type %s of package %s in %s, use -out to write it to another directory", tableName, name, pkgName, dir)
+			}
+		}
+		declared = append(declared, structName)
+
+		source, err := generateModel(pkgName, strings.ToLower(tableName), columns)
+		if err != nil {
+			return written, err
+		}
+
+		path := filepath.Join(dir, "model_"+strings.ToLower(tableName)+".go")
+		if err := os.WriteFile(path, source, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// loadPackage returns the package name of dir and the types declared in its files,
+// creating dir if it does not exist
+//
+// The generated files in skip are left out. An empty directory gets a package named after it.
+func loadPackage(dir string, skip map[string]bool) (string, []string, error) {
+	var pkgName string
+	var declared []string
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return "", nil, err
+		}
+		pkgName = file.Name.Name
+
+		if skip[filepath.Base(path)] && ast.IsGenerated(file) {
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				declared = append(declared, spec.(*ast.TypeSpec).Name.Name)
+			}
+		}
+	}
+
+	// A new package is named after its directory
+	if pkgName == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", nil, err
+		}
+		pkgName = strings.ToLower(filepath.Base(abs))
+		if !token.IsIdentifier(pkgName) {
+			return "", nil, fmt.Errorf("the directory name %q is not a valid package name", pkgName)
+		}
+	}
+	return pkgName, declared, nil
+}
+
+// generateModel returns the Go source of the model struct of a table, in package pkgName
+//
+// The struct has a field for every column, with the "db", "json" and "pk" tags
+// and the "type", "nullable", "unique" and "default" tags read by schema.go.
+// The rows are scanned by the "db" tags, see scanTargets, so no scan method is needed.
+func generateModel(pkgName string, tableName string, columns []dbColumn) ([]byte, error) {
+	var fields bytes.Buffer
+	var usesTime bool
+
+	structName := goName(singular(tableName))
+
+	for _, col := range columns {
+		fieldName := goName(col.Name)
+		fieldType := goType(col, pkgName == "main")
+		usesTime = usesTime || strings.Contains(fieldType, "time.")
+
+		tags := fmt.Sprintf(`db:"%s" json:"%s"`, col.Name, col.Name)
+		if col.PK {
+			tags += fmt.Sprintf(` pk:"%s"`, col.Name)
+		}
+		if col.Unique {
+			tags += fmt.Sprintf(` unique:"%s"`, col.Name)
+		}
+
+		// A column that defaults to a sequence is a SERIAL column
+		if strings.HasPrefix(col.Default, "nextval(") {
+			tags += fmt.Sprintf(` type:"%s"`, serialType(col.SQLType))
+		} else {
+			tags += fmt.Sprintf(` type:"%s"`, col.SQLType)
+		}
+
+		if col.Nullable {
+			tags += ` nullable:"true"`
+		}
+
+		// A nullable deleted_at timestamp marks the soft deleted rows, see deleteSql
+		if col.Name == "deleted_at" && col.Nullable && fieldType == "nullTimestamp" {
+			tags += ` softdelete:"true"`
+		}
+
+		if col.Default != "" && !strings.HasPrefix(col.Default, "nextval(") {
+			tags += fmt.Sprintf(` default:"%s"`, strings.ReplaceAll(col.Default, `"`, `'`))
+		}
+
+		fmt.Fprintf(&fields, "\t%s %s `%s`\n", fieldName, fieldType, tags)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by \"go run . gen models\" from the %s table. DO NOT EDIT.\n\n", tableName)
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	if usesTime {
+		fmt.Fprintf(&buf, "import \"time\"\n\n")
+	}
+
+	fmt.Fprintf(&buf, "// %s is a row of the %s table\n", structName, tableName)
+	fmt.Fprintf(&buf, "type %s struct {\n", structName)
+	buf.Write(fields.Bytes())
+	fmt.Fprintf(&buf, "}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+// goType returns the Go type a column is scanned into
+//
+// In package main, NUMERIC columns with two decimal places are Money, and the other
+// NUMERIC columns are decimals, so amounts are not rounded by a float.
+// The nullable date, timestamp and text columns use the null types of model.go,
+// and the other nullable columns are pointers, so NULL is scanned as nil and written as JSON null.
+//
+// The types of model.go are not visible from another package, so there the dates are
+// time.Time, the NUMERIC columns are strings and every nullable column is a pointer.
+func goType(col dbColumn, inMain bool) string {
+	var goType string
+
+	name, _, _ := strings.Cut(col.SQLType, "(")
+	switch name {
+	case "INTEGER", "SMALLINT":
+		goType = "int"
+	case "BIGINT":
+		goType = "int64"
+	case "BOOLEAN":
+		goType = "bool"
+	case "DATE":
+		if !inMain {
+			goType = "time.Time"
+			break
+		}
+		if col.Nullable {
+			return "nullDate"
+		}
+		return "date"
+	case "TIMESTAMP", "TIMESTAMPTZ":
+		if !inMain {
+			goType = "time.Time"
+			break
+		}
+		if col.Nullable {
+			return "nullTimestamp"
+		}
+		return "timestamp"
+	case "REAL", "DOUBLE PRECISION":
+		goType = "float64"
+	case "NUMERIC":
+		goType = "string"
+		if inMain {
+			goType = "decimal"
+			if strings.HasSuffix(col.SQLType, ",2)") {
+				goType = "Money"
+			}
+		}
+	default:
+		goType = "string"
+		if inMain && col.Nullable {
+			return "nullString"
+		}
+	}
+
+	if col.Nullable {
+		return "*" + goType
+	}
+	return goType
+}
+
+// serialType returns the SERIAL type that matches an integer type
+func serialType(sqlType string) string {
+	switch sqlType {
+	case "BIGINT":
+		return "BIGSERIAL"
+	case "SMALLINT":
+		return "SMALLSERIAL"
+	default:
+		return "SERIAL"
+	}
+}
+
+// goName converts a snake_case name into an exported Go name, such as member_id into MemberID
+func goName(name string) string {
+	var b strings.Builder
+
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		if upper, ok := initialisms[word]; ok {
+			b.WriteString(upper)
+		} else {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// singular returns the singular form of a plural table name, such as members into member
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	default:
+		return name
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"go-api-prosgres/migrations"
+)
+
+// usage is printed when a command is not recognised
+const usage = `usage:
+  go run .                      start the server
+  go run . migrate up           apply all pending migrations
+  go run . migrate down [steps] revert the latest migration, or the given number of migrations
+  go run . migrate status       list the migrations and whether they are applied
+  go run . schema ddl           print the CREATE TABLE statements generated from the model structs
+  go run . schema check         compare the model structs with the database and report the drift
+  go run . gen models [-out dir] [table ...]
+                                write a model_<table>.go file for the given tables, or all tables,
+                                into the package in dir (./models by default)`
+
+// runCommand runs the command given on the command line instead of the server
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "schema":
+		return schemaCommand(args[1:])
+	case "gen":
+		return genCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+// migrateCommand runs the "migrate up|down|status" subcommand
+func migrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate subcommand\n%s", usage)
+	}
+
+	// Connect to the database, without applying the migrations on start
+	openDb()
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Applied", len(applied), "migrations")
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+		reverted, err := migrations.Down(db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Reverted", len(reverted), "migrations")
+
+	case "status":
+		statuses, err := migrations.List(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Printf("%04d_%s\tapplied at %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", s.Version, s.Name)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q\n%s", args[0], usage)
+	}
+	return nil
+}
+
+// schemaCommand runs the "schema ddl|check" subcommand
+func schemaCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing schema subcommand\n%s", usage)
+	}
+
+	switch args[0] {
+	case "ddl":
+		// Print the DDL of every table, it does not need the database
+		for _, t := range schemaTables {
+			ddl, err := createTableSql(t.Table, t.Name)
+			if err != nil {
+				return err
+			}
+			fmt.Println(ddl)
+			fmt.Println()
+		}
+
+	case "check":
+		// Connect to the database, without applying the migrations on start
+		openDb()
+		defer db.Close()
+
+		var drifts []Drift
+		for _, t := range schemaTables {
+			tableDrifts, err := checkDrift(t.Table, t.Name)
+			if err != nil {
+				return err
+			}
+			drifts = append(drifts, tableDrifts...)
+		}
+
+		for _, d := range drifts {
+			fmt.Println(d)
+		}
+		if len(drifts) > 0 {
+			return fmt.Errorf("found %d differences between the structs and the database", len(drifts))
+		}
+		fmt.Println("The structs match the database")
+
+	default:
+		return fmt.Errorf("unknown schema subcommand %q\n%s", args[0], usage)
+	}
+	return nil
+}
+
+// genCommand runs the "gen models" subcommand
+func genCommand(args []string) error {
+	if len(args) == 0 || args[0] != "models" {
+		return fmt.Errorf("missing or unknown gen subcommand\n%s", usage)
+	}
+
+	// Parse the output directory and the tables
+	flags := flag.NewFlagSet("gen models", flag.ContinueOnError)
+	out := flags.String("out", "models", "the directory of the package the model files are written to")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// Connect to the database, without applying the migrations on start
+	openDb()
+	defer db.Close()
+
+	written, err := generateModels(*out, flags.Args()...)
+	if err != nil {
+		return err
+	}
+	for _, path := range written {
+		fmt.Println("Wrote", path)
+	}
+	return nil
+}
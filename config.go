@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 const (
 	host        = "localhost"
 	port        = 5432
@@ -7,4 +9,12 @@ const (
 	password    = "pgadmin"
 	dbname      = "postgres"
 	skipColumns = "updated_at,created_at"
+	pageLimit   = 50    // the number of rows in a page when the request does not set a limit
+	maxLimit    = 500   // the largest limit a request can ask for
+	maxBatch    = 10000 // the largest number of rows a batch request can insert
+
+	migrateOnStart = true // apply the pending migrations when the server starts
+
+	renewInterval = time.Minute // how often the due subscriptions are renewed
+	renewBatch    = 100         // the largest number of subscriptions renewed in a single run
 )
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"go-api-prosgres/migrations"
+
 	_ "github.com/lib/pq"
 )
 
@@ -11,7 +13,28 @@ var db *sql.DB
 var err error
 
 // connDb establishes a connection to the PostgreSQL database
+// and, if migrateOnStart is set, applies the pending migrations
 func connDb() {
+	openDb()
+
+	if migrateOnStart {
+		// Log the migrations being applied
+		fmt.Println("DEBUG: Applying pending migrations...")
+
+		applied, err := migrations.Up(db)
+		if err != nil {
+			// If there was an error applying a migration, log it and panic
+			fmt.Println("ERROR: Failed to migrate database:", err)
+			panic(err)
+		}
+
+		// Log the number of migrations applied
+		fmt.Println("DEBUG: Applied", len(applied), "migrations")
+	}
+}
+
+// openDb opens the connection pool to the PostgreSQL database and checks that it is reachable
+func openDb() {
 	// Construct the PostgreSQL connection string
 	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
 
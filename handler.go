@@ -1,64 +1,79 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 type Response struct {
 	Message string `json:"message"`
 }
 
-// getMember retrieves a member or multiple members from the database
-//
-// If no member ID is provided, it retrieves all members
-// If one or more member IDs are provided, it retrieves those members
-//
-// Parameters:
-//
-//	id ...int - The ID of the member(s) to retrieve
-//
-// Returns:
-//
-//	[]Member - The member(s) retrieved from the database
-//	error - Any error that may have occurred
-func getMember(id ...int) ([]Member, error) {
-	var members []Member
-	var member Member
+// UpsertResponse is the row written by an upsert,
+// with whether it was "inserted" or "updated"
+type UpsertResponse struct {
+	Result string `json:"result"`
+	Data   any    `json:"data"`
+}
 
-	// Create the SELECT SQL statement
-	sqlQuery := selectSql(member, "members", id...)
+// BatchResponse is the result of a batch, with the rows that were written
+// and the errors of the items that were not
+type BatchResponse struct {
+	Data   any          `json:"data"`
+	Errors []BatchError `json:"errors,omitempty"`
+}
 
-	// Log the SQL query being executed
-	log.Println("Executing SQL query:", sqlQuery)
+// DeleteResponse is the result of a delete, with the number of deleted rows
+// and the IDs that did not exist
+type DeleteResponse struct {
+	Deleted  int   `json:"deleted"`
+	NotFound []any `json:"not_found"`
+}
 
-	// Execute the SQL query
-	rows, err := db.Query(sqlQuery)
-	if err != nil {
-		// If there is an error executing the query, return the error
-		log.Println("Error executing query:", err.Error())
-		return members, err
-	}
-	defer rows.Close() // Close the rows result set when finished
-
-	// Convert the rows result set to JSON
-	// err = convertToJson(rows, member.Fields())
-	for rows.Next() {
-		if err := rows.Scan(member.Fields()...); err != nil {
-			fmt.Println(err.Error())
-			return members, err
-		}
-		members = append(members, member)
+// ListResponse is a page of a list endpoint
+//
+// NextCursor is passed as the "cursor" parameter to get the next page,
+// it is empty when there are no more rows.
+type ListResponse struct {
+	Data       any    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// writePage writes a page of items and the cursor of the next page as the response of a list request,
+// or the error of getting the page: 400 for a filter value the column cannot hold, such as
+// member_id=abc, and 500 for any other error. name is what the items are, such as "members".
+func writePage[T any](w http.ResponseWriter, items []T, nextCursor string, query Query, err error, name string) {
+	if isDataException(err) {
+		log.Println("Invalid filter value:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		log.Println("Error getting "+name+":", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to get " + name + "!"}
+		json.NewEncoder(w).Encode(response)
+		return
 	}
-	// Return the members retrieved from the database
-	return members, err
+
+	// If there is no error, return the items and the cursor of the next page
+	log.Println("Returning", len(items), name)
+	json.NewEncoder(w).Encode(ListResponse{Data: pickAllFields(items, query.Fields), NextCursor: nextCursor})
 }
 
+// memberRepo reads and writes members in the members table
+var memberRepo = NewRepository[Member]("members")
+
 // getMemberHandle handles GET requests to /members/{member_id}
 // This function gets a single member from the database
 // based on the member_id in the URL
@@ -67,7 +82,7 @@ func getMemberHandle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Declare a variable to store the member struct
-	var members []Member
+	var member Member
 	// Declare a variable to store any error that may occur
 	var err error
 
@@ -82,12 +97,31 @@ func getMemberHandle(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
+	// Parse the fields to return from the query string
+	colNames, _ := getColumns(member)
+	fields, err := parseFields(colNames, r.URL.Query().Get("fields"))
+	if err != nil {
+		// If the fields are invalid, return a failure message
+		log.Println("Error parsing fields:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// Log the member ID being requested
 	log.Println("Getting member with ID:", memberId)
 
 	// Get the member from the database
-	members, err = getMember(memberId)
-	if err != nil {
+	member, err = memberRepo.Get(Key{memberId}, fields...)
+	if err == sql.ErrNoRows {
+		// If the member does not exist, return a not found message
+		log.Println("Member not found:", memberId)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Member not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
 		// If there is an error getting the member, return a failure message
 		log.Println("Error getting member:", err.Error())
 		response := Response{Message: "Failed to get member!"}
@@ -95,42 +129,51 @@ func getMemberHandle(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	// If there is no error, return the member
-	log.Println("Returning member:", members[0])
-	json.NewEncoder(w).Encode(members[0])
+	// If there is no error, return the member with the requested fields
+	log.Println("Returning member:", member)
+	if len(fields) > 0 {
+		json.NewEncoder(w).Encode(pickFields(member, fields))
+	} else {
+		json.NewEncoder(w).Encode(member)
+	}
 }
 
 // getMembersHandle handles GET requests to /members
 // This function gets all members from the database
+// that match the filters in the query string
 func getMembersHandle(w http.ResponseWriter, r *http.Request) {
 	// Set the content type of the response to JSON
 	w.Header().Set("Content-Type", "application/json")
 
 	// Declare a variable to store the member structs
 	var members []Member
+	// Declare a variable to store the cursor of the next page
+	var nextCursor string
 	// Declare a variable to store any error that may occur
 	var err error
 
-	// Log the SQL statement being executed
-	log.Println("Getting all members")
-
-	// Get all members from the database
-	members, err = getMember()
+	// Parse the filters from the query string
+	query, err := parseQuery(Member{}, r.URL.Query())
 	if err != nil {
-		// If there is an error, return a failure message
-		log.Println("Error getting members:", err.Error())
-		response := Response{Message: "Failed to get members!"}
+		// If the query string is invalid, return a failure message
+		log.Println("Error parsing query:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
 		json.NewEncoder(w).Encode(response)
-		panic(err)
+		return
 	}
 
-	// If there is no error, return the members
-	log.Println("Returning members:", members)
-	json.NewEncoder(w).Encode(members)
+	// Log the filters being applied
+	log.Println("Getting members with filters:", query.Filters)
+
+	// Get a page of the members that match the filters from the database
+	members, nextCursor, err = memberRepo.Page(query)
+	writePage(w, members, nextCursor, query, err, "members")
 }
 
 // CreateMemberHandle handles POST requests to /members
 // This function creates a new member in the database
+// and returns it with 201 Created and its Location
 func createMemberHandle(w http.ResponseWriter, r *http.Request) {
 	// Set the content type of the response to JSON
 	w.Header().Set("Content-Type", "application/json")
@@ -143,43 +186,189 @@ func createMemberHandle(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// If there is an error decoding the JSON body, return a failure message
 		log.Println("Error decoding JSON body:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
 		response := Response{Message: "Failed to decode JSON body!"}
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	// Marshal the member struct to JSON
-	data, err := json.Marshal(member)
+	// Insert the member into the database
+	member, err = memberRepo.Create(member)
 	if err != nil {
-		// If there is an error marshalling the member, return a failure message
-		log.Println("Error marshalling member:", err.Error())
-		panic(err)
+		writeMemberError(w, err, "Failed to insert!")
+		return
 	}
 
-	// Create an INSERT SQL statement to insert the member
-	sqlScript := updateOrInsertSql("members", string(data), "insert")
+	// If there is no error, return the created member and where to find it
+	log.Println("Inserted member successfully!")
+	w.Header().Set("Location", fmt.Sprintf("/members/%d", member.MemberID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+// writeMemberError writes the response for an error of inserting a member:
+// 409 if the email is already taken, 400 if a value is missing or invalid, and 500 otherwise
+func writeMemberError(w http.ResponseWriter, err error, message string) {
+	log.Println("Error inserting member:", err.Error())
+
+	var response Response
+	switch {
+	case isUniqueViolation(err):
+		w.WriteHeader(http.StatusConflict)
+		response = Response{Message: "A member with this email already exists!"}
+	case isNotNullViolation(err) || isDataException(err):
+		w.WriteHeader(http.StatusBadRequest)
+		response = Response{Message: "Invalid member: " + err.Error()}
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response = Response{Message: message}
+	}
+	json.NewEncoder(w).Encode(response)
+}
 
-	// Log the SQL statement being executed
-	log.Println("Executing SQL:", sqlScript)
+// createMembersBatchHandle handles POST requests to /members:batch
+// This function creates all the members in the JSON array of the body in one transaction.
+//
+// With ?mode=atomic, the default, either all members are created or none is.
+// With ?mode=best_effort, the members that fail are skipped and returned in "errors"
+// with their index in the array, and the other members are still created.
+func createMembersBatchHandle(w http.ResponseWriter, r *http.Request) {
+	// Set the content type of the response to JSON
+	w.Header().Set("Content-Type", "application/json")
+
+	// Declare a variable to store the member structs
+	var members []Member
 
-	// Execute the SQL statement
-	_, err = db.Query(sqlScript)
+	// Get the mode of the batch
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "atomic"
+	}
+	if mode != "atomic" && mode != "best_effort" {
+		log.Println("Invalid batch mode:", mode)
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "The mode must be atomic or best_effort!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Decode the JSON array of the body into the member structs
+	err := json.NewDecoder(r.Body).Decode(&members)
+	if err != nil {
+		// If there is an error decoding the JSON body, return a failure message
+		log.Println("Error decoding JSON body:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed to decode JSON body!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Check the size of the batch
+	if len(members) == 0 || len(members) > maxBatch {
+		log.Println("Invalid batch size:", len(members))
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: fmt.Sprintf("The batch must have between 1 and %d members!", maxBatch)}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Creating", len(members), "members in", mode, "mode")
+
+	// Insert the members into the database
+	created, batchErrors, err := memberRepo.CreateMany(members, mode == "atomic")
 	if err != nil {
+		// If the batch failed, none of the members was created
+		log.Println("Error inserting members:", err.Error())
+		if isConstraintViolation(err) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		response := Response{Message: "Failed to insert! " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// If all members were created, return them with 201 Created
+	log.Println("Inserted", len(created), "members successfully!")
+	if len(batchErrors) == 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(BatchResponse{Data: created, Errors: batchErrors})
+}
+
+// upsertMemberHandle handles PUT requests to /members?on_conflict={columns}
+// This function inserts a member, or updates the member that has the same
+// values in the on_conflict columns, such as ?on_conflict=email.
+// It returns the member with 201 Created if it was inserted, or 200 OK if it was updated
+func upsertMemberHandle(w http.ResponseWriter, r *http.Request) {
+	// Set the content type of the response to JSON
+	w.Header().Set("Content-Type", "application/json")
+
+	// Declare a variable to store the member struct
+	var member Member
+
+	// Get the columns that identify an existing member
+	onConflict := r.URL.Query().Get("on_conflict")
+	if onConflict == "" {
+		log.Println("Missing on_conflict parameter")
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "The on_conflict parameter is required!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	conflictColumns := strings.Split(onConflict, ",")
+
+	// Decode the JSON body of the request into the member struct
+	err := json.NewDecoder(r.Body).Decode(&member)
+	if err != nil {
+		// If there is an error decoding the JSON body, return a failure message
+		log.Println("Error decoding JSON body:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed to decode JSON body!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Insert or update the member in the database
+	member, inserted, err := memberRepo.Upsert(member, conflictColumns...)
+	if errors.Is(err, errInvalidInput) {
+		// If the conflict columns are not a unique key, return a failure message
+		log.Println("Invalid upsert:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err == sql.ErrNoRows {
+		// If the existing member is soft deleted, it has to be restored before it is updated
+		log.Println("Upsert conflicts with a deleted member:", onConflict)
+		w.WriteHeader(http.StatusConflict)
+		response := Response{Message: "The member is deleted, restore it first!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
 		// If there is an error executing the SQL statement, return a failure message
-		log.Println("Error inserting member:", err.Error())
-		response := Response{Message: "Failed to insert!"}
+		log.Println("Error upserting member:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to upsert!"}
 		json.NewEncoder(w).Encode(response)
-		panic(err)
+		return
 	}
 
-	// If there is no error, return a success message
-	log.Println("Inserted member successfully!")
-	response := Response{Message: "Success!"}
-	json.NewEncoder(w).Encode(response)
+	// If there is no error, return the member and whether it was inserted or updated
+	if inserted {
+		log.Println("Inserted member successfully!")
+		w.Header().Set("Location", fmt.Sprintf("/members/%d", member.MemberID))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(UpsertResponse{Result: "inserted", Data: member})
+	} else {
+		log.Println("Updated member successfully!")
+		json.NewEncoder(w).Encode(UpsertResponse{Result: "updated", Data: member})
+	}
 }
 
 // UpdateMemberHandle handles PUT requests to /members/{member_id}
 // This function updates a member in the database
+// and returns it as it was stored
 func updateMemberHandle(w http.ResponseWriter, r *http.Request) {
 	// Set the content type of the response to JSON
 	w.Header().Set("Content-Type", "application/json")
@@ -203,14 +392,6 @@ func updateMemberHandle(w http.ResponseWriter, r *http.Request) {
 	json.NewDecoder(r.Body).Decode(&member)
 	log.Println("Member object:", member)
 
-	// Marshal the member struct to JSON
-	data, err := json.Marshal(member)
-	if err != nil {
-		// If there is an error marshalling the member, return a failure message
-		log.Println("Error marshalling member:", err.Error())
-		log.Fatal(err.Error())
-	}
-
 	// Check if the member ID in the URL matches the member ID in the JSON
 	if id != member.MemberID {
 		// If the IDs don't match, return a failure message
@@ -220,49 +401,253 @@ func updateMemberHandle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create an UPDATE SQL statement to update the member
-	sqlScript := updateOrInsertSql("members", string(data), "update")
-	log.Println("Executing SQL:", sqlScript)
-
-	// Execute the SQL statement
-	_, err = db.Query(sqlScript)
-	if err != nil {
+	// Update the member in the database
+	member, err = memberRepo.Update(member)
+	if err == sql.ErrNoRows {
+		// If the member does not exist, return a not found message
+		log.Println("Member not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Member not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
 		// If there is an error executing the SQL statement, return a failure message
 		log.Println("Error updating member:", err.Error())
 		panic(err)
 	}
 
-	// If there is no error, return a success message
+	// If there is no error, return the updated member
 	log.Println("Updated member successfully!")
-	response := Response{Message: "Success!"}
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(member)
+}
+
+// patchMemberHandle handles PATCH requests to /members/{member_id}
+// This function updates only the fields present in the body, following
+// JSON merge patch (RFC 7386): a field set to null clears the column
+func patchMemberHandle(w http.ResponseWriter, r *http.Request) {
+	// Set the content type of the response to JSON
+	w.Header().Set("Content-Type", "application/json")
+
+	// Declare a variable to store the patch, by field name
+	var patch map[string]json.RawMessage
+
+	// Get the member ID from the URL
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["member_id"])
+	if err != nil {
+		// If there is an error converting the member ID to an int, return a failure message
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Patching member with ID:", id)
+
+	// The body has to be a merge patch, or plain JSON
+	contentType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	if contentType != "" && contentType != "application/merge-patch+json" && contentType != "application/json" {
+		log.Println("Unsupported content type:", contentType)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		response := Response{Message: "The body must be application/merge-patch+json!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Decode the JSON body of the request, it has to be an object
+	err = json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil || patch == nil {
+		// If there is an error decoding the JSON body, return a failure message
+		log.Println("Error decoding JSON body:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "The body must be a JSON object!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Apply the patch to the member in the database
+	member, err := memberRepo.Patch(Key{id}, patch)
+	if errors.Is(err, errInvalidInput) || isNotNullViolation(err) {
+		// If the patch is invalid, return a failure message
+		log.Println("Invalid patch:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err == sql.ErrNoRows {
+		// If the member does not exist, return a not found message
+		log.Println("Member not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Member not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		// If there is an error executing the SQL statement, return a failure message
+		log.Println("Error patching member:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to update!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// If there is no error, return the updated member
+	log.Println("Patched member successfully!")
+	json.NewEncoder(w).Encode(member)
+}
+
+// isNotNullViolation checks if an error is caused by setting a NOT NULL column to NULL
+func isNotNullViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23502"
+}
+
+// isUniqueViolation checks if an error is caused by a duplicate value in a unique column
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation checks if an error is caused by a row that is still referenced
+// by another table, or that references a row that does not exist
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// isDataException checks if an error is caused by a value the column cannot hold,
+// such as a fee that is not a number
+func isDataException(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Class() == "22"
+}
 
-	return
+// isConstraintViolation checks if an error is caused by the data breaking a constraint
+// of the table, such as a unique or NOT NULL constraint
+func isConstraintViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Class() == "23"
 }
 
 // DeleteMemberHandle handles DELETE requests to /members/{member_id}
 // This function deletes a member from the database
+// based on the member_id in the URL
 func deleteMemberHandle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Create a DELETE SQL statement to delete a member
-	sqlScript := deleteSql(Member{}, "members", 1)
+	// Get the member ID from the URL
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["member_id"])
+	if err != nil {
+		// If there is an error converting the member ID to an int, return a failure message
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Deleting member with ID:", id)
+
+	deleteMembers(w, []int{id})
+}
+
+// deleteMembersHandle handles DELETE requests to /members?ids={ids}
+// This function deletes the members with the comma separated IDs in the query string
+func deleteMembersHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var ids []int // The IDs of the members to delete
+
+	// Get the member IDs from the query string
+	for _, item := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			// If an ID is not a number, return a failure message
+			log.Println("Invalid member ID:", item)
+			w.WriteHeader(http.StatusBadRequest)
+			response := Response{Message: "The ids parameter must be a comma separated list of member IDs!"}
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		ids = append(ids, id)
+	}
+	log.Println("Deleting members with IDs:", ids)
 
-	// Log the SQL statement being executed
-	log.Println("Executing SQL:", sqlScript)
+	deleteMembers(w, ids)
+}
+
+// restoreMemberHandle handles POST requests to /members/{member_id}/restore
+// This function brings back a deleted member and returns it as it was stored
+func restoreMemberHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	// Execute the SQL statement
-	_, err := db.Query(sqlScript)
+	// Get the member ID from the URL
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["member_id"])
+	if err != nil {
+		// If there is an error converting the member ID to an int, return a failure message
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Restoring member with ID:", id)
+
+	// Restore the member in the database
+	member, err := memberRepo.Restore(Key{id})
+	if err == sql.ErrNoRows {
+		// If there is no deleted member with the ID, return a not found message
+		log.Println("Deleted member not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Deleted member not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		// If there is an error executing the SQL statement, return a failure message
+		log.Println("Error restoring member:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to restore!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// If there is no error, return the restored member
+	log.Println("Restored member successfully!")
+	json.NewEncoder(w).Encode(member)
+}
+
+// deleteMembers deletes the members with the given IDs and writes the response
+// with how many members were deleted and which IDs did not exist.
+// If a single member is deleted and it does not exist, the status is 404 Not Found.
+func deleteMembers(w http.ResponseWriter, ids []int) {
+	keys := make([]Key, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, Key{id})
+	}
+
+	// Delete the members from the database
+	deleted, notFound, err := memberRepo.Delete(keys...)
 	if err != nil {
 		// If there is an error, return a failure message
-		log.Println("Error deleting member:", err.Error())
+		// The members are only marked as deleted, so their subscriptions and payments cannot block it
+		log.Println("Error deleting members:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
 		response := Response{Message: "Failed to delete!"}
 		json.NewEncoder(w).Encode(response)
-		panic(err)
+		return
+	}
+
+	// Report the IDs that did not exist
+	response := DeleteResponse{Deleted: len(deleted), NotFound: []any{}}
+	for _, key := range notFound {
+		response.NotFound = append(response.NotFound, key[0])
+	}
+
+	if len(ids) == 1 && len(deleted) == 0 {
+		log.Println("Member not found:", ids[0])
+		w.WriteHeader(http.StatusNotFound)
 	} else {
-		// If there is no error, return a success message
-		log.Println("Deleted member successfully!")
-		response := Response{Message: "Success!"}
-		json.NewEncoder(w).Encode(response)
+		log.Println("Deleted", len(deleted), "members successfully!")
 	}
+	json.NewEncoder(w).Encode(response)
 }
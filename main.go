@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 )
@@ -12,10 +13,21 @@ import (
 
 func main() {
 
+	// If a command is given, run it instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Connect to the database
 	connDb()
 	defer db.Close() // Ensure the database connection is closed when the function exits
 
+	// Renew the subscriptions that are due, in the background
+	startRenewals()
+
 	// Create a new router
 	r := mux.NewRouter()
 
@@ -25,10 +37,38 @@ func main() {
 	r.HandleFunc("/members/{member_id:[0-9]+}", getMemberHandle).Methods("GET")
 	// Handle POST requests to the /members endpoint
 	r.HandleFunc("/members", createMemberHandle).Methods("POST")
+	// Handle POST requests to the /members:batch endpoint, to create many members at once
+	r.HandleFunc("/members:batch", createMembersBatchHandle).Methods("POST")
+	// Handle PUT requests to the /members endpoint, to insert or update by the ?on_conflict columns
+	r.HandleFunc("/members", upsertMemberHandle).Methods("PUT")
 	// Handle PUT requests to the /members/{member_id} endpoint
 	r.HandleFunc("/members/{member_id:[0-9]+}", updateMemberHandle).Methods("PUT")
+	// Handle PATCH requests to the /members/{member_id} endpoint
+	r.HandleFunc("/members/{member_id:[0-9]+}", patchMemberHandle).Methods("PATCH")
 	// Handle DELETE requests to the /members/{member_id} endpoint
 	r.HandleFunc("/members/{member_id:[0-9]+}", deleteMemberHandle).Methods("DELETE")
+	// Handle DELETE requests to the /members?ids={ids} endpoint
+	r.HandleFunc("/members", deleteMembersHandle).Methods("DELETE").Queries("ids", "{ids}")
+	// Handle POST requests to the /members/{member_id}/restore endpoint, to bring back a deleted member
+	r.HandleFunc("/members/{member_id:[0-9]+}/restore", restoreMemberHandle).Methods("POST")
+
+	// Handle the /membership-types endpoints, the plans members can subscribe to
+	r.HandleFunc("/membership-types", getMembershipTypesHandle).Methods("GET")
+	r.HandleFunc("/membership-types/{type_id:[0-9]+}", getMembershipTypeHandle).Methods("GET")
+	r.HandleFunc("/membership-types", createMembershipTypeHandle).Methods("POST")
+	r.HandleFunc("/membership-types/{type_id:[0-9]+}", updateMembershipTypeHandle).Methods("PUT")
+	r.HandleFunc("/membership-types/{type_id:[0-9]+}", deleteMembershipTypeHandle).Methods("DELETE")
+
+	// Handle the subscriptions of the members
+	r.HandleFunc("/members/{member_id:[0-9]+}/subscriptions", getMemberSubscriptionsHandle).Methods("GET")
+	r.HandleFunc("/members/{member_id:[0-9]+}/subscriptions", createMemberSubscriptionHandle).Methods("POST")
+	r.HandleFunc("/subscriptions/{subscription_id:[0-9]+}", getSubscriptionHandle).Methods("GET")
+
+	// Handle the payment records, of all members or of a single member
+	r.HandleFunc("/payments", getPaymentsHandle).Methods("GET")
+	r.HandleFunc("/payments/{payment_id:[0-9]+}", getPaymentHandle).Methods("GET")
+	r.HandleFunc("/members/{member_id:[0-9]+}/payments", getMemberPaymentsHandle).Methods("GET")
+	r.HandleFunc("/members/{member_id:[0-9]+}/payments", createMemberPaymentHandle).Methods("POST")
 
 	// Start the server and log any errors
 	log.Fatal(http.ListenAndServe(":8000", r))
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// membershipTypeRepo reads and writes membership types in the MembershipTypes table
+var membershipTypeRepo = NewRepository[MembershipType]("membershiptypes")
+
+// getMembershipTypesHandle handles GET requests to /membership-types
+// This function gets the membership types that match the filters in the query string
+func getMembershipTypesHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse the filters from the query string
+	query, err := parseQuery(MembershipType{}, r.URL.Query())
+	if err != nil {
+		// If the query string is invalid, return a failure message
+		log.Println("Error parsing query:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Getting membership types with filters:", query.Filters)
+
+	// Get a page of the membership types from the database
+	types, nextCursor, err := membershipTypeRepo.Page(query)
+	writePage(w, types, nextCursor, query, err, "membership types")
+}
+
+// getMembershipTypeHandle handles GET requests to /membership-types/{type_id}
+// This function gets a single membership type based on the type_id in the URL
+func getMembershipTypeHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the type ID from the URL
+	id, err := strconv.Atoi(mux.Vars(r)["type_id"])
+	if err != nil {
+		log.Println("Error converting type_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid membership type ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Parse the fields to return from the query string
+	colNames, _ := getColumns(MembershipType{})
+	fields, err := parseFields(colNames, r.URL.Query().Get("fields"))
+	if err != nil {
+		log.Println("Error parsing fields:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Getting membership type with ID:", id)
+
+	// Get the membership type from the database
+	membershipType, err := membershipTypeRepo.Get(Key{id}, fields...)
+	if err == sql.ErrNoRows {
+		log.Println("Membership type not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Membership type not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		log.Println("Error getting membership type:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to get membership type!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// If there is no error, return the membership type with the requested fields
+	if len(fields) > 0 {
+		json.NewEncoder(w).Encode(pickFields(membershipType, fields))
+	} else {
+		json.NewEncoder(w).Encode(membershipType)
+	}
+}
+
+// createMembershipTypeHandle handles POST requests to /membership-types
+// This function creates a new membership type and returns it with 201 Created and its Location.
+// The type_name has to be unique, a duplicate is refused with 409 Conflict.
+func createMembershipTypeHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var membershipType MembershipType
+
+	// Decode the JSON body of the request into the membership type struct
+	if err := json.NewDecoder(r.Body).Decode(&membershipType); err != nil {
+		log.Println("Error decoding JSON body:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed to decode JSON body!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Insert the membership type into the database
+	membershipType, err := membershipTypeRepo.Create(membershipType)
+	if err != nil {
+		writeMembershipTypeError(w, err, "Failed to insert!")
+		return
+	}
+
+	// If there is no error, return the created membership type and where to find it
+	log.Println("Inserted membership type successfully!")
+	w.Header().Set("Location", fmt.Sprintf("/membership-types/%d", membershipType.TypeID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(membershipType)
+}
+
+// updateMembershipTypeHandle handles PUT requests to /membership-types/{type_id}
+// This function updates a membership type and returns it as it was stored
+func updateMembershipTypeHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var membershipType MembershipType
+
+	// Get the type ID from the URL
+	id, err := strconv.Atoi(mux.Vars(r)["type_id"])
+	if err != nil {
+		log.Println("Error converting type_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid membership type ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Updating membership type with ID:", id)
+
+	// Decode the JSON body of the request into the membership type struct
+	if err := json.NewDecoder(r.Body).Decode(&membershipType); err != nil {
+		log.Println("Error decoding JSON body:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed to decode JSON body!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The ID in the body has to be the ID in the URL, if it is given
+	if membershipType.TypeID != 0 && membershipType.TypeID != id {
+		log.Println("ID mismatch:", id, "!=", membershipType.TypeID)
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed! ID mismatch"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	membershipType.TypeID = id
+
+	// Update the membership type in the database
+	membershipType, err = membershipTypeRepo.Update(membershipType)
+	if err == sql.ErrNoRows {
+		log.Println("Membership type not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Membership type not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		writeMembershipTypeError(w, err, "Failed to update!")
+		return
+	}
+
+	// If there is no error, return the updated membership type
+	log.Println("Updated membership type successfully!")
+	json.NewEncoder(w).Encode(membershipType)
+}
+
+// deleteMembershipTypeHandle handles DELETE requests to /membership-types/{type_id}
+// This function deletes a membership type. A type that still has subscriptions
+// is refused with 409 Conflict, since the subscriptions reference it.
+func deleteMembershipTypeHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the type ID from the URL
+	id, err := strconv.Atoi(mux.Vars(r)["type_id"])
+	if err != nil {
+		log.Println("Error converting type_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid membership type ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Deleting membership type with ID:", id)
+
+	// Delete the membership type from the database
+	deleted, _, err := membershipTypeRepo.Delete(Key{id})
+	if isForeignKeyViolation(err) {
+		// The subscriptions of the type reference it, so it cannot be deleted
+		log.Println("Membership type still has subscriptions:", id)
+		w.WriteHeader(http.StatusConflict)
+		response := Response{Message: "The membership type still has subscriptions!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		log.Println("Error deleting membership type:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to delete!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if len(deleted) == 0 {
+		log.Println("Membership type not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Membership type not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	log.Println("Deleted membership type successfully!")
+	json.NewEncoder(w).Encode(DeleteResponse{Deleted: len(deleted), NotFound: []any{}})
+}
+
+// writeMembershipTypeError writes the response of a failed insert or update of a membership type
+//
+// A duplicate type_name is a 409 Conflict, a missing or invalid value is a 400 Bad Request,
+// and any other error is a 500 Internal Server Error with the given message.
+func writeMembershipTypeError(w http.ResponseWriter, err error, message string) {
+	log.Println("Error writing membership type:", err.Error())
+
+	var response Response
+	switch {
+	case isUniqueViolation(err):
+		w.WriteHeader(http.StatusConflict)
+		response = Response{Message: "A membership type with this type_name already exists!"}
+	case isNotNullViolation(err) || isDataException(err):
+		w.WriteHeader(http.StatusBadRequest)
+		response = Response{Message: "Invalid membership type: " + err.Error()}
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		response = Response{Message: message}
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// tableMeta is what the SQL builders need to know about a struct stored in a table
+//
+// It is read from the struct tags once per type by metaOf, and shared by all
+// handlers afterwards, so it must not be modified. The slices have no spare
+// capacity, so appending to them makes a copy instead of writing into the cache.
+type tableMeta struct {
+	Columns     []string                       // The "db" columns, in the order of the struct
+	PKColumns   []string                       // The "pk" columns, in the order of the struct
+	Unique      []string                       // The columns with a "unique" tag
+	ReadOnly    []string                       // The columns in skipColumns, never written by an update or insert
+	Nullable    []string                       // The columns with a "nullable" tag, which can hold NULL
+	Text        []string                       // The columns with a text "type" tag, which LIKE can match
+	SoftDelete  string                         // The column with a "softdelete" tag, empty if the rows are deleted for good
+	JSONColumns map[string]string              // The column of each field by its JSON name
+	Fields      map[string]reflect.StructField // The field of each column, with its index
+}
+
+// metas caches the tableMeta of every struct type, it maps a reflect.Type to a *tableMeta
+var metas sync.Map
+
+// metaOf returns the metadata of the struct type of table, or of the struct ptr points to
+func metaOf(table interface{}) *tableMeta {
+	reflectType := reflect.TypeOf(table)
+	if reflectType.Kind() == reflect.Pointer {
+		reflectType = reflectType.Elem()
+	}
+
+	if cached, ok := metas.Load(reflectType); ok {
+		return cached.(*tableMeta)
+	}
+
+	// If another handler stored the type first, use its metadata
+	cached, _ := metas.LoadOrStore(reflectType, newTableMeta(reflectType))
+	return cached.(*tableMeta)
+}
+
+// newTableMeta reads the metadata of a struct type from its tags
+func newTableMeta(reflectType reflect.Type) *tableMeta {
+	meta := &tableMeta{
+		JSONColumns: make(map[string]string),
+		Fields:      make(map[string]reflect.StructField),
+	}
+
+	readOnly := parseSkipColumns()
+
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+
+		// The "pk" tag holds the column name, the same as the "db" tag
+		if pk, ok := field.Tag.Lookup("pk"); ok {
+			meta.PKColumns = append(meta.PKColumns, pk)
+		}
+
+		colName, ok := field.Tag.Lookup("db")
+		if !ok {
+			// if the tag is not found, the field is not a column
+			continue
+		}
+		meta.Columns = append(meta.Columns, colName)
+		meta.Fields[colName] = field
+
+		if isNullable(field) {
+			meta.Nullable = append(meta.Nullable, colName)
+		}
+		if isTextType(field.Tag.Get("type")) {
+			meta.Text = append(meta.Text, colName)
+		}
+		if unique, ok := field.Tag.Lookup("unique"); ok {
+			meta.Unique = append(meta.Unique, unique)
+		}
+		// The soft delete column is only set by a delete and cleared by a restore
+		if field.Tag.Get("softdelete") == "true" {
+			meta.SoftDelete = colName
+			meta.ReadOnly = append(meta.ReadOnly, colName)
+		} else if inColumns(colName, readOnly) {
+			meta.ReadOnly = append(meta.ReadOnly, colName)
+		}
+
+		// Use the name from the "json" tag, the same as encoding/json
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tagName, _, _ := strings.Cut(tag, ","); tagName == "-" {
+				continue
+			} else if tagName != "" {
+				name = tagName
+			}
+		}
+		meta.JSONColumns[name] = colName
+	}
+
+	meta.Columns = slices.Clip(meta.Columns)
+	meta.PKColumns = slices.Clip(meta.PKColumns)
+	meta.Unique = slices.Clip(meta.Unique)
+	meta.Nullable = slices.Clip(meta.Nullable)
+	meta.Text = slices.Clip(meta.Text)
+	meta.ReadOnly = slices.Clip(meta.ReadOnly)
+	return meta
+}
+
+// isTextType reports whether a SQL type holds text, such as VARCHAR(255) or TEXT
+func isTextType(sqlType string) bool {
+	name, _, _ := strings.Cut(normalizeType(sqlType), "(")
+	switch name {
+	case "VARCHAR", "CHAR", "CHARACTER", "TEXT", "CITEXT":
+		return true
+	}
+	return false
+}
+
+// parseSkipColumns splits the skipColumns setting into the column names
+func parseSkipColumns() []string {
+	columns := strings.Split(skipColumns, ",") // Split the string by comma
+	for i, col := range columns {
+		columns[i] = strings.TrimSpace(col) // Trim leading and trailing whitespace from each part
+	}
+	return columns
+}
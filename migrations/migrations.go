@@ -0,0 +1,277 @@
+// Package migrations applies the versioned changes of the database schema.
+//
+// Each change is a pair of SQL files in the sql directory, embedded in the binary:
+//
+//	0001_init.up.sql    applies the change
+//	0001_init.down.sql  reverts it
+//
+// The applied versions are tracked in the schema_migrations table, and every run
+// holds a Postgres advisory lock, so two instances starting at the same time
+// cannot apply the same migration twice.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID is the key of the advisory lock held while migrating.
+// The value is arbitrary, it only has to be the same for every instance.
+const lockID = 8_472_615
+
+// Migration is a versioned change of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // The SQL that applies the change
+	Down    string // The SQL that reverts the change
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load returns the embedded migrations, sorted by version
+//
+// The files are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// every version has to have both files.
+func Load() ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		// Split the file name into its version, name and direction
+		base, direction, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), ".")
+		versionStr, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		content, err := files.ReadFile("sql/" + fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies all migrations that have not been applied yet, in order of version
+//
+// Each migration runs in its own transaction, together with its row in schema_migrations,
+// so a failed migration leaves the schema at the previous version.
+// It returns the migrations that were applied.
+func Up(db *sql.DB) ([]Migration, error) {
+	var applied []Migration
+
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			log.Printf("Applying migration %d_%s", m.Version, m.Name)
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the given number of applied migrations, starting from the latest version
+//
+// It returns the migrations that were reverted.
+func Down(db *sql.DB, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		// Revert the applied migrations from the latest version down
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+
+			log.Printf("Reverting migration %d_%s", m.Version, m.Name)
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// List returns every migration and whether it has been applied
+func List(db *sql.DB) ([]Status, error) {
+	var statuses []Status
+
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			appliedAt, ok := done[m.Version]
+			statuses = append(statuses, Status{Migration: m, Applied: ok, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a single connection that holds the migration advisory lock
+//
+// The advisory lock belongs to the session, so the lock, the migrations and
+// the unlock have to use the same connection rather than the pool.
+func withLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Wait until no other instance is migrating
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+
+	// Create the tracking table on the first run
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP(2) NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the versions in schema_migrations and when they were applied
+func appliedVersions(conn *sql.Conn) (map[int]time.Time, error) {
+	done := make(map[int]time.Time)
+
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// inTx runs fn in a transaction on the connection, and commits it if fn succeeds
+func inTx(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
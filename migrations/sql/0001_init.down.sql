@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS PaymentRecords;
+DROP TABLE IF EXISTS Subscriptions;
+DROP TABLE IF EXISTS MembershipTypes;
+DROP TABLE IF EXISTS Members;
+
+DROP FUNCTION IF EXISTS moddatetime();
@@ -0,0 +1,2 @@
+-- The soft deleted members become active members again once the column is dropped
+ALTER TABLE Members DROP COLUMN IF EXISTS deleted_at;
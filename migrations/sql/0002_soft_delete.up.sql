@@ -0,0 +1,2 @@
+-- Deleted members are kept, with the time they were deleted, so they can be restored
+ALTER TABLE Members ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP(2);
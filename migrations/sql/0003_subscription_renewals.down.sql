@@ -0,0 +1 @@
+ALTER TABLE Subscriptions DROP COLUMN IF EXISTS renewed_from;
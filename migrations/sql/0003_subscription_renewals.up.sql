@@ -0,0 +1,3 @@
+-- A renewed subscription points to the subscription it follows. The column is unique,
+-- so a subscription can only be renewed once, even if the renewal runs twice.
+ALTER TABLE Subscriptions ADD COLUMN IF NOT EXISTS renewed_from INT UNIQUE REFERENCES Subscriptions(subscription_id);
@@ -1,26 +1,98 @@
 package main
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Member is a row of the Members table
+//
+// Besides "db", "json" and "pk", the tags describe the column for the DDL generator
+// and the drift checker in schema.go: "type" is the SQL type, "nullable" allows NULL,
+// "unique" adds a unique constraint and "default" is the default value.
+// The nullable columns use the null types below, so NULL is kept apart from an empty value.
+// "softdelete" marks the column that is set when the row is deleted, see deleteSql.
 type Member struct {
-	MemberID       int       `db:"member_id" json:"member_id" pk:"member_id"`
-	FirstName      string    `db:"first_name" json:"first_name"`
-	LastName       string    `db:"last_name" json:"last_name"`
-	Email          string    `db:"email" json:"email"`
-	PasswordHash   string    `db:"password_hash" json:"password_hash"`
-	DateOfBirth    date      `db:"date_of_birth" json:"date_of_birth"`
-	JoinDate       timestamp `db:"join_date" json:"join_date"`
-	MembershipType string    `db:"membership_type" json:"membership_type"`
-	Status         string    `db:"status" json:"status"`
-	CreatedAt      timestamp `db:"created_at" json:"created_at"`
-	UpdatedAt      timestamp `db:"updated_at" json:"updated_at"`
+	MemberID       int           `db:"member_id" json:"member_id" pk:"member_id" type:"SERIAL"`
+	FirstName      string        `db:"first_name" json:"first_name" type:"VARCHAR(255)"`
+	LastName       string        `db:"last_name" json:"last_name" type:"VARCHAR(255)"`
+	Email          string        `db:"email" json:"email" unique:"email" type:"VARCHAR(255)"`
+	PasswordHash   string        `db:"password_hash" json:"password_hash" type:"TEXT"`
+	DateOfBirth    nullDate      `db:"date_of_birth" json:"date_of_birth" type:"DATE" nullable:"true"`
+	JoinDate       timestamp     `db:"join_date" json:"join_date" type:"TIMESTAMP(2)"`
+	MembershipType nullString    `db:"membership_type" json:"membership_type" type:"VARCHAR(255)" nullable:"true"`
+	Status         nullString    `db:"status" json:"status" type:"VARCHAR(255)" nullable:"true"`
+	CreatedAt      timestamp     `db:"created_at" json:"created_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+	UpdatedAt      timestamp     `db:"updated_at" json:"updated_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+	DeletedAt      nullTimestamp `db:"deleted_at" json:"deleted_at" type:"TIMESTAMP(2)" nullable:"true" softdelete:"true"`
 }
 
-func (m *Member) Fields() []any {
-	return []any{&m.MemberID, &m.FirstName, &m.LastName, &m.Email, &m.PasswordHash, &m.DateOfBirth, &m.JoinDate, &m.MembershipType, &m.Status, &m.CreatedAt, &m.UpdatedAt}
+// MembershipType is a row of the MembershipTypes table, a plan members can subscribe to
+//
+// Duration is the length of a subscription in months, it is NULL for a lifetime plan.
+type MembershipType struct {
+	TypeID    int        `db:"type_id" json:"type_id" pk:"type_id" type:"SERIAL"`
+	TypeName  string     `db:"type_name" json:"type_name" unique:"type_name" type:"VARCHAR(255)"`
+	Duration  *int       `db:"duration" json:"duration" type:"INTEGER" nullable:"true"`
+	Fee       Money      `db:"fee" json:"fee" type:"NUMERIC(10, 2)"`
+	Benefits  nullString `db:"benefits" json:"benefits" type:"TEXT" nullable:"true"`
+	CreatedAt timestamp  `db:"created_at" json:"created_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+	UpdatedAt timestamp  `db:"updated_at" json:"updated_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+}
+
+// endDate returns when a subscription to the membership type that starts at start ends,
+// Duration months later, or NULL if the membership type is a lifetime plan
+func (t MembershipType) endDate(start timestamp) nullTimestamp {
+	if t.Duration == nil {
+		return nullTimestamp{}
+	}
+	return nullTimestamp{timestamp: timestamp{addMonths(start.Time, *t.Duration)}, Valid: true}
+}
+
+// addMonths adds months to a time the way Postgres adds an interval of months:
+// if the day does not exist in the resulting month, it is the last day of that month,
+// so January 31 plus one month is February 28 and not March 3.
+func addMonths(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return firstOfMonth.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
+
+// Subscription is a row of the Subscriptions table, a member subscribed to a membership type
+//
+// EndDate is NULL for a lifetime plan. RenewedFrom is the subscription this one renews,
+// it is set by the renewal worker, see renewal.go. MembershipType is not a column, it is the
+// membership type of TypeID, loaded by the handlers to be returned with the subscription.
+type Subscription struct {
+	SubscriptionID int             `db:"subscription_id" json:"subscription_id" pk:"subscription_id" type:"SERIAL"`
+	MemberID       int             `db:"member_id" json:"member_id" type:"INTEGER"`
+	TypeID         int             `db:"type_id" json:"type_id" type:"INTEGER"`
+	StartDate      timestamp       `db:"start_date" json:"start_date" type:"TIMESTAMP(2)"`
+	EndDate        nullTimestamp   `db:"end_date" json:"end_date" type:"TIMESTAMP" nullable:"true"`
+	AutoRenew      *bool           `db:"auto_renew" json:"auto_renew" type:"BOOLEAN" nullable:"true"`
+	RenewedFrom    *int            `db:"renewed_from" json:"renewed_from" unique:"renewed_from" type:"INTEGER" nullable:"true"`
+	CreatedAt      timestamp       `db:"created_at" json:"created_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+	UpdatedAt      timestamp       `db:"updated_at" json:"updated_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+	MembershipType *MembershipType `json:"membership_type,omitempty"`
+}
+
+// PaymentRecord is a row of the PaymentRecords table, a payment made by a member
+type PaymentRecord struct {
+	PaymentID     int        `db:"payment_id" json:"payment_id" pk:"payment_id" type:"SERIAL"`
+	MemberID      int        `db:"member_id" json:"member_id" type:"INTEGER"`
+	Amount        Money      `db:"amount" json:"amount" type:"NUMERIC(10, 2)"`
+	PaymentDate   timestamp  `db:"payment_date" json:"payment_date" type:"TIMESTAMP(2)"`
+	PaymentMethod nullString `db:"payment_method" json:"payment_method" type:"VARCHAR(255)" nullable:"true"`
+	Status        nullString `db:"status" json:"status" type:"VARCHAR(255)" nullable:"true"`
+	CreatedAt     timestamp  `db:"created_at" json:"created_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
+	UpdatedAt     timestamp  `db:"updated_at" json:"updated_at" type:"TIMESTAMP(2)" default:"CURRENT_TIMESTAMP"`
 }
 
 type timestamp struct {
@@ -50,14 +122,30 @@ func (d *timestamp) Scan(value interface{}) error {
 		return nil
 	}
 	t, ok := value.(time.Time)
-	fmt.Printf("\n%v\n", t)
 	if !ok {
-		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type *date", value)
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type *timestamp", value)
 	}
 	d.Time = t
 	return nil
 }
 
+// Value implements the driver.Valuer interface and returns the date as a DATE literal
+//
+// Only the calendar day is sent, so the date does not move to another day
+// when the time zone of the connection differs from the one it was parsed in.
+func (d date) Value() (driver.Value, error) {
+	return d.Format(time.DateOnly), nil
+}
+
+// Value implements the driver.Valuer interface and returns the timestamp in UTC
+//
+// The columns are TIMESTAMP without time zone, which drop the offset of the value
+// they are given, so the timestamps are always stored as UTC wall clock times.
+// The driver scans them back with the UTC location.
+func (ct timestamp) Value() (driver.Value, error) {
+	return ct.UTC(), nil
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface and parses the
 // JSON encoding of the timestamp. The format is in RFC3339, the same as used by
 // the time.Time type.
@@ -102,3 +190,288 @@ func (ct *date) UnmarshalJSON(b []byte) error {
 func (ct date) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + ct.Format(time.DateOnly) + `"`), nil
 }
+
+// nullDate is a date that can be NULL
+// NULL is scanned from and written to the database as NULL, and to JSON as null.
+type nullDate struct {
+	date
+	Valid bool // Valid is true if the date is not NULL
+}
+
+func (d *nullDate) Scan(value interface{}) error {
+	if value == nil {
+		*d = nullDate{}
+		return nil
+	}
+	d.Valid = true
+	return d.date.Scan(value)
+}
+
+// Value implements the driver.Valuer interface, it returns nil for NULL
+func (d nullDate) Value() (driver.Value, error) {
+	if !d.Valid {
+		return nil, nil
+	}
+	return d.date.Value()
+}
+
+func (d *nullDate) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*d = nullDate{}
+		return nil
+	}
+	d.Valid = true
+	return d.date.UnmarshalJSON(b)
+}
+
+func (d nullDate) MarshalJSON() ([]byte, error) {
+	if !d.Valid {
+		return []byte("null"), nil
+	}
+	return d.date.MarshalJSON()
+}
+
+// nullTimestamp is a timestamp that can be NULL
+// NULL is scanned from and written to the database as NULL, and to JSON as null.
+type nullTimestamp struct {
+	timestamp
+	Valid bool // Valid is true if the timestamp is not NULL
+}
+
+func (t *nullTimestamp) Scan(value interface{}) error {
+	if value == nil {
+		*t = nullTimestamp{}
+		return nil
+	}
+	t.Valid = true
+	return t.timestamp.Scan(value)
+}
+
+// Value implements the driver.Valuer interface, it returns nil for NULL
+func (t nullTimestamp) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.timestamp.Value()
+}
+
+func (t *nullTimestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*t = nullTimestamp{}
+		return nil
+	}
+	t.Valid = true
+	return t.timestamp.UnmarshalJSON(b)
+}
+
+func (t nullTimestamp) MarshalJSON() ([]byte, error) {
+	if !t.Valid {
+		return []byte("null"), nil
+	}
+	return t.timestamp.MarshalJSON()
+}
+
+// nullString is a text column that can be NULL
+// It is scanned and written like sql.NullString, and NULL is written to JSON as null.
+type nullString struct {
+	sql.NullString
+}
+
+func (s *nullString) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*s = nullString{}
+		return nil
+	}
+	s.Valid = true
+	return json.Unmarshal(b, &s.String)
+}
+
+func (s nullString) MarshalJSON() ([]byte, error) {
+	if !s.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.String)
+}
+
+// decimal is an exact decimal number of a NUMERIC column of any scale,
+// the amounts of money with two decimal places use Money instead
+//
+// It keeps the digits as they are written, and is passed to and from the database
+// and JSON as text, so it is never rounded by a float on the way.
+// In JSON it is written as a number, and read from a number or a string.
+type decimal string
+
+// decimalPattern matches the decimal numbers, without an exponent
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+func (d *decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*d = decimal(v)
+	case string:
+		*d = decimal(v)
+	case int64:
+		*d = decimal(strconv.FormatInt(v, 10))
+	default:
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type *decimal", value)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface and returns the decimal as text,
+// which Postgres converts to the NUMERIC type of the column without rounding
+func (d decimal) Value() (driver.Value, error) {
+	if !decimalPattern.MatchString(string(d)) {
+		return nil, fmt.Errorf("invalid decimal %q", string(d))
+	}
+	return string(d), nil
+}
+
+func (d *decimal) UnmarshalJSON(b []byte) error {
+	// Accept the number with or without quotes
+	s := strings.Trim(string(b), `"`)
+
+	if !decimalPattern.MatchString(s) {
+		return fmt.Errorf("invalid decimal %s", string(b))
+	}
+	*d = decimal(s)
+	return nil
+}
+
+func (d decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// Money is an amount of money with two decimal places, such as a fee or a payment,
+// for the NUMERIC(10, 2) columns
+//
+// It is a whole number of cents, so the amounts are added, subtracted and prorated
+// with integer arithmetic and never rounded by a float. It is read from and written
+// to the database and JSON as a decimal number, such as 12.50, and a value with more
+// than two decimal places is rejected rather than rounded.
+type Money int64
+
+// moneyPattern matches an amount with at most two decimal places,
+// the sign, the units and the decimals are its groups
+var moneyPattern = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]*))?$`)
+
+// parseMoney parses a decimal number, such as "12.5" or "-0.75", into Money
+func parseMoney(s string) (Money, error) {
+	match := moneyPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	sign, units, decimals := match[1], match[2], match[3]
+
+	// The trailing zeros do not change the amount, "12.500" is 12.50
+	decimals = strings.TrimRight(decimals, "0")
+	if len(decimals) > 2 {
+		return 0, fmt.Errorf("invalid amount %q: more than two decimal places", s)
+	}
+
+	cents, err := strconv.ParseInt(units+(decimals + "00")[:2], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: out of range", s)
+	}
+	if sign == "-" {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
+
+// String returns the amount as a decimal number with two decimal places, such as "12.50"
+func (m Money) String() string {
+	sign := ""
+	cents := uint64(m)
+	if m < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Add returns the sum of the two amounts
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+// Sub returns the amount minus the other amount
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// MulRatio returns the amount multiplied by numerator/denominator, rounded to the nearest cent,
+// with the halves rounded away from zero. It is used to prorate an amount, such as a fee
+// for 3 of the 12 months of a plan. It panics if the denominator is 0, the same as an integer division.
+//
+// The product is computed with big integers, so it cannot overflow before the division.
+// It panics if the result does not fit in Money, rather than wrapping around to a wrong amount.
+func (m Money) MulRatio(numerator, denominator int64) Money {
+	if denominator == 0 {
+		panic("Money.MulRatio: denominator is 0")
+	}
+
+	product := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(numerator))
+	den := big.NewInt(denominator)
+
+	// Work on the absolute values, and put the sign back after rounding
+	negative := product.Sign()*den.Sign() < 0
+	product.Abs(product)
+	den.Abs(den)
+
+	quotient, remainder := new(big.Int).QuoRem(product, den, new(big.Int))
+
+	// Round the half cents and above up
+	if remainder.Lsh(remainder, 1).Cmp(den) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if negative {
+		quotient.Neg(quotient)
+	}
+	if !quotient.IsInt64() {
+		panic(fmt.Sprintf("Money.MulRatio: %s * %d / %d overflows", m, numerator, denominator))
+	}
+	return Money(quotient.Int64())
+}
+
+func (m *Money) Scan(value interface{}) error {
+	var err error
+
+	switch v := value.(type) {
+	case []byte:
+		*m, err = parseMoney(string(v))
+	case string:
+		*m, err = parseMoney(v)
+	case int64:
+		*m = Money(v * 100)
+	default:
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type *Money", value)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer interface and returns the amount as a decimal text,
+// which Postgres converts to the NUMERIC type of the column without rounding
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// UnmarshalJSON parses an amount written as a JSON number or string,
+// and rejects an amount with more than two decimal places
+func (m *Money) UnmarshalJSON(b []byte) error {
+	// Accept the number with or without quotes
+	amount, err := parseMoney(strings.Trim(string(b), `"`))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// MarshalJSON writes the amount as a JSON number with two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+		err   string // A part of the expected error, empty if the amount is valid
+	}{
+		{input: "12.50", want: 1250},
+		{input: "12.5", want: 1250},
+		{input: "12.500", want: 1250},
+		{input: "12", want: 1200},
+		{input: "12.", want: 1200},
+		{input: "0.07", want: 7},
+		{input: "-0.75", want: -75},
+		{input: "-12.500", want: -1250},
+		{input: "92233720368547758.07", want: 9223372036854775807},
+		{input: "12.505", err: "more than two decimal places"},
+		{input: "-12.505", err: "more than two decimal places"},
+		{input: "92233720368547758.08", err: "out of range"},
+		{input: "100000000000000000000", err: "out of range"},
+		{input: "", err: "invalid amount"},
+		{input: "1e3", err: "invalid amount"},
+		{input: "+1.00", err: "invalid amount"},
+		{input: ".50", err: "invalid amount"},
+	}
+
+	for _, test := range tests {
+		got, err := parseMoney(test.input)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseMoney(%q) error = %v, want an error containing %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMoney(%q) error = %v", test.input, err)
+		} else if got != test.want {
+			t.Errorf("parseMoney(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		amount      Money
+		numerator   int64
+		denominator int64
+		want        Money
+		panics      bool // Whether the result cannot be computed
+	}{
+		{amount: 1200, numerator: 3, denominator: 12, want: 300},
+		{amount: 1000, numerator: 1, denominator: 3, want: 333}, // 333.33 rounds down
+		{amount: 1000, numerator: 2, denominator: 3, want: 667}, // 666.67 rounds up
+		{amount: 5, numerator: 1, denominator: 2, want: 3},      // 2.5 rounds away from zero
+		{amount: -5, numerator: 1, denominator: 2, want: -3},    // -2.5 rounds away from zero
+		{amount: 5, numerator: -1, denominator: 2, want: -3},    // The sign of the numerator counts
+		{amount: 5, numerator: 1, denominator: -2, want: -3},    // The sign of the denominator counts
+		{amount: -5, numerator: -1, denominator: 2, want: 3},    // Two negative signs give a positive amount
+		{amount: 15, numerator: 1, denominator: 10, want: 2},    // 1.5 rounds away from zero
+		{amount: -14, numerator: 1, denominator: 10, want: -1},  // -1.4 rounds toward zero
+		{amount: 0, numerator: 7, denominator: 9, want: 0},
+		{amount: math.MaxInt64, numerator: 2, denominator: 4, want: 4611686018427387904}, // The product does not overflow
+		{amount: math.MinInt64, numerator: 1, denominator: 1, want: math.MinInt64},
+		{amount: math.MaxInt64, numerator: 2, denominator: 1, panics: true},  // The result does not fit in Money
+		{amount: math.MinInt64, numerator: -1, denominator: 1, panics: true}, // The result does not fit in Money
+		{amount: 100, numerator: 1, denominator: 0, panics: true},
+	}
+
+	for _, test := range tests {
+		got, panicked := mulRatio(test.amount, test.numerator, test.denominator)
+		if panicked != test.panics {
+			t.Errorf("Money(%d).MulRatio(%d, %d) panicked = %v, want %v", test.amount, test.numerator, test.denominator, panicked, test.panics)
+		} else if !test.panics && got != test.want {
+			t.Errorf("Money(%d).MulRatio(%d, %d) = %d, want %d", test.amount, test.numerator, test.denominator, got, test.want)
+		}
+	}
+}
+
+// mulRatio calls Money.MulRatio and reports whether it panicked
+func mulRatio(amount Money, numerator, denominator int64) (result Money, panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	return amount.MulRatio(numerator, denominator), false
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		value any
+		want  Money
+		err   bool
+	}{
+		{value: []byte("12.50"), want: 1250},
+		{value: []byte("-0.75"), want: -75},
+		{value: "12.50", want: 1250},
+		{value: "12.500", want: 1250},
+		{value: int64(12), want: 1200},
+		{value: []byte("12.505"), err: true},
+		{value: []byte("abc"), err: true},
+		{value: 12.5, err: true},
+		{value: nil, err: true},
+	}
+
+	for _, test := range tests {
+		var got Money
+		err := got.Scan(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %d, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v) error = %v", test.value, err)
+		} else if got != test.want {
+			t.Errorf("Scan(%#v) = %d, want %d", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// paymentRepo reads and writes payments in the PaymentRecords table
+var paymentRepo = NewRepository[PaymentRecord]("paymentrecords")
+
+// getPaymentsHandle handles GET requests to /payments
+// This function gets the payments that match the filters in the query string, such as
+//
+//	?payment_date[gte]=2024-01-01&payment_date[lt]=2024-02-01
+//	?status=completed&payment_method[in]=credit card,PayPal
+func getPaymentsHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse the filters from the query string
+	query, err := parseQuery(PaymentRecord{}, r.URL.Query())
+	if err != nil {
+		log.Println("Error parsing query:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	writePayments(w, query)
+}
+
+// getMemberPaymentsHandle handles GET requests to /members/{member_id}/payments
+// This function gets the payments of a member that match the filters in the query string
+func getMemberPaymentsHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the member ID from the URL
+	memberID, err := strconv.Atoi(mux.Vars(r)["member_id"])
+	if err != nil {
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Parse the filters from the query string
+	query, err := parseQuery(PaymentRecord{}, r.URL.Query())
+	if err != nil {
+		log.Println("Error parsing query:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The member has to exist, so an unknown member is not an empty list
+	if !checkMemberExists(w, memberID) {
+		return
+	}
+
+	// Only list the payments of the member
+	query.Filters = append(query.Filters, Filter{Column: "member_id", Operator: "eq", Values: []any{memberID}})
+
+	writePayments(w, query)
+}
+
+// writePayments gets a page of the payments that match the query and writes it as the response
+func writePayments(w http.ResponseWriter, query Query) {
+	log.Println("Getting payments with filters:", query.Filters)
+
+	// Get a page of the payments from the database
+	payments, nextCursor, err := paymentRepo.Page(query)
+	writePage(w, payments, nextCursor, query, err, "payments")
+}
+
+// getPaymentHandle handles GET requests to /payments/{payment_id}
+// This function gets a single payment based on the payment_id in the URL
+func getPaymentHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the payment ID from the URL
+	id, err := strconv.Atoi(mux.Vars(r)["payment_id"])
+	if err != nil {
+		log.Println("Error converting payment_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid payment ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Getting payment with ID:", id)
+
+	// Get the payment from the database
+	payment, err := paymentRepo.Get(Key{id})
+	if err == sql.ErrNoRows {
+		log.Println("Payment not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Payment not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		log.Println("Error getting payment:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to get payment!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	json.NewEncoder(w).Encode(payment)
+}
+
+// createMemberPaymentHandle handles POST requests to /members/{member_id}/payments
+// This function records a payment of the member, and returns it with 201 Created and its Location.
+// The payment_date defaults to now.
+func createMemberPaymentHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var payment PaymentRecord
+
+	// Get the member ID from the URL
+	memberID, err := strconv.Atoi(mux.Vars(r)["member_id"])
+	if err != nil {
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Decode the JSON body of the request into the payment struct,
+	// the amount is checked to have at most two decimal places while it is decoded
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil || payment.Amount == 0 {
+		log.Println("Error decoding JSON body:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "The body must be a payment with an amount of at most two decimal places!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The member in the body has to be the member in the URL, if it is given
+	if payment.MemberID != 0 && payment.MemberID != memberID {
+		log.Println("ID mismatch:", memberID, "!=", payment.MemberID)
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed! ID mismatch"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	payment.MemberID = memberID
+
+	// The member has to exist
+	if !checkMemberExists(w, memberID) {
+		return
+	}
+
+	if payment.PaymentDate.IsZero() {
+		payment.PaymentDate = timestamp{time.Now().UTC()}
+	}
+
+	// Insert the payment into the database
+	payment, err = paymentRepo.Create(payment)
+	if err != nil {
+		log.Println("Error inserting payment:", err.Error())
+		var response Response
+		if isDataException(err) {
+			// Such as an amount that does not fit NUMERIC(10, 2)
+			w.WriteHeader(http.StatusBadRequest)
+			response = Response{Message: "Invalid payment: " + err.Error()}
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			response = Response{Message: "Failed to insert!"}
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// If there is no error, return the created payment and where to find it
+	log.Println("Inserted payment successfully!")
+	w.Header().Set("Location", fmt.Sprintf("/payments/%d", payment.PaymentID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
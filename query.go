@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filter is a condition on a single column of a list query
+//
+// The values are bound as parameters, so they are never written into the SQL query.
+// Every operator takes a single value, except "in" that takes one or more.
+type Filter struct {
+	Column   string
+	Operator string
+	Values   []any
+}
+
+// SortKey is a column a list query is ordered by
+type SortKey struct {
+	Column     string
+	Descending bool
+}
+
+// String returns the sort key as it is written in a cursor, with a "-" prefix when it is descending
+func (k SortKey) String() string {
+	if k.Descending {
+		return "-" + k.Column
+	}
+	return k.Column
+}
+
+// Cursor is the position in a list after which the next page starts
+//
+// It holds the values of the order columns of the last row of the previous page,
+// and the order it was taken in, so it cannot be used with a different order.
+// It is handed to clients as an opaque base64 string, see encodeCursor.
+type Cursor struct {
+	Columns []string `json:"c"`
+	Values  []any    `json:"v"`
+}
+
+// Query holds the options of a list query, parsed from the query string of the request
+type Query struct {
+	Filters []Filter
+	Sort    []SortKey // The order of the rows, the primary key is always added as the last key
+	Limit   int       // The maximum number of rows, 0 means no limit
+	Cursor  *Cursor   // The position to continue from, nil for the first page
+	Fields  []string  // The columns to return, all columns if empty
+
+	IncludeDeleted bool // Whether soft deleted rows are returned too
+}
+
+// reservedParams are the query string parameters that are options of the query instead of filters
+var reservedParams = []string{"limit", "cursor", "sort", "fields", "include_deleted"}
+
+// filterOperators maps the operators accepted in the query string to their SQL operator
+var filterOperators = map[string]string{
+	"eq":    "=",
+	"ne":    "<>",
+	"gt":    ">",
+	"gte":   ">=",
+	"lt":    "<",
+	"lte":   "<=",
+	"like":  "LIKE",
+	"ilike": "ILIKE",
+	"in":    "IN",
+}
+
+// parseQuery parses the query string of a list request into a Query
+//
+// Every parameter is a filter on the column with the same name, the operator
+// defaults to "eq" and can be set in brackets after the column name:
+//
+//	?status=active&membership_type=monthly
+//	?join_date[gte]=2024-01-01&last_name[like]=Sm%
+//	?status[in]=active,suspended
+//
+// The columns are checked against the "db" tags of the given struct,
+// so an unknown column or operator is returned as an error.
+//
+// The reserved parameters "limit" and "cursor" set the size of the page
+// and the position to continue from, "sort" sets the order of the rows
+// and "fields" the columns that are returned:
+//
+//	?sort=-join_date,last_name
+//	?fields=member_id,first_name,email
+//
+// If the struct has a "softdelete" column, "include_deleted=true" also returns the deleted rows.
+func parseQuery(table interface{}, values url.Values) (Query, error) {
+	var query Query
+	var err error
+
+	colNames, _ := getColumns(table) // Get the column names of the table
+
+	// Parse the columns to return
+	query.Fields, err = parseFields(colNames, values.Get("fields"))
+	if err != nil {
+		return query, err
+	}
+
+	// Parse the order of the rows
+	if sortParam := values.Get("sort"); sortParam != "" {
+		query.Sort, err = parseSort(colNames, sortParam)
+		if err != nil {
+			return query, err
+		}
+	}
+
+	// Parse the size of the page
+	query.Limit = pageLimit
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLimit {
+			return query, fmt.Errorf("limit must be a number between 1 and %d", maxLimit)
+		}
+		query.Limit = n
+	}
+
+	// Parse whether the soft deleted rows are returned
+	if includeDeleted := values.Get("include_deleted"); includeDeleted != "" {
+		query.IncludeDeleted, err = strconv.ParseBool(includeDeleted)
+		if err != nil {
+			return query, fmt.Errorf("include_deleted must be true or false")
+		}
+	}
+
+	// Sort the parameters, so the same query string always gives the same SQL query
+	params := make([]string, 0, len(values))
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		paramValues := values[param]
+
+		// Skip the parameters that are not filters
+		if inColumns(param, reservedParams) {
+			continue
+		}
+
+		// Split the parameter into the column name and the operator
+		column, operator, err := parseFilterParam(param)
+		if err != nil {
+			return query, err
+		}
+
+		// Check the filter against the columns and operators that are allowed
+		if err := checkFilter(table, column, operator); err != nil {
+			return query, err
+		}
+
+		// Add a filter for every value, so a repeated parameter matches all of its values
+		for _, value := range paramValues {
+			filter := Filter{Column: column, Operator: operator}
+
+			if operator == "in" {
+				for _, item := range strings.Split(value, ",") {
+					filter.Values = append(filter.Values, item)
+				}
+			} else {
+				filter.Values = append(filter.Values, value)
+			}
+			query.Filters = append(query.Filters, filter)
+		}
+	}
+
+	// Parse the position to continue from, it has to be taken in the order of this query
+	if cursor := values.Get("cursor"); cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		_, pkColNames := getColumns(table)
+		if err := checkCursor(c, orderKeys(query, pkColNames)); err != nil {
+			return query, err
+		}
+		query.Cursor = c
+	}
+	return query, nil
+}
+
+// parseFields parses a comma separated list of columns
+// It returns nil if the list is empty, which selects all columns.
+func parseFields(colNames []string, fields string) ([]string, error) {
+	var columns []string
+
+	if fields == "" {
+		return nil, nil
+	}
+
+	for _, item := range strings.Split(fields, ",") {
+		col := strings.TrimSpace(item)
+
+		if !inColumns(col, colNames) {
+			return nil, fmt.Errorf("unknown field %q", col)
+		}
+		// Skip the columns that are listed more than once
+		if !inColumns(col, columns) {
+			columns = append(columns, col)
+		}
+	}
+	return columns, nil
+}
+
+// parseSort parses a comma separated list of columns into sort keys
+// A column with a "-" prefix is sorted in descending order.
+func parseSort(colNames []string, sort string) ([]SortKey, error) {
+	var keys []SortKey
+	var seen []string // The columns that are already sorted on
+
+	for _, item := range strings.Split(sort, ",") {
+		key := SortKey{Column: strings.TrimSpace(item)}
+		if strings.HasPrefix(key.Column, "-") {
+			key.Column = key.Column[1:]
+			key.Descending = true
+		}
+
+		if !inColumns(key.Column, colNames) {
+			return nil, fmt.Errorf("unknown sort column %q", key.Column)
+		}
+		if inColumns(key.Column, seen) {
+			return nil, fmt.Errorf("column %q is sorted on more than once", key.Column)
+		}
+		seen = append(seen, key.Column)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseFilterParam splits a query string parameter such as "join_date[gte]"
+// into its column name and operator. The operator defaults to "eq".
+func parseFilterParam(param string) (string, string, error) {
+
+	open := strings.Index(param, "[")
+
+	// If there are no brackets, the parameter is an equality filter
+	if open == -1 {
+		return param, "eq", nil
+	}
+
+	// The brackets have to close at the end of the parameter
+	if !strings.HasSuffix(param, "]") {
+		return "", "", fmt.Errorf("invalid filter %q", param)
+	}
+
+	return param[:open], param[open+1 : len(param)-1], nil
+}
+
+// checkFilter returns an error if the column is not a column of the struct,
+// if the operator is not a supported filter operator, or if the operator is
+// like or ilike and the column does not hold text
+func checkFilter(table interface{}, column, operator string) error {
+	meta := metaOf(table)
+
+	if !inColumns(column, meta.Columns) {
+		return fmt.Errorf("unknown column %q", column)
+	}
+
+	if _, ok := filterOperators[operator]; !ok {
+		return fmt.Errorf("unknown operator %q for column %q", operator, column)
+	}
+
+	// Postgres has no LIKE operator for the other types, such as INTEGER
+	if (operator == "like" || operator == "ilike") && !inColumns(column, meta.Text) {
+		return fmt.Errorf("operator %q only applies to text columns, not %q", operator, column)
+	}
+	return nil
+}
+
+// orderKeys returns the keys a query is ordered by
+//
+// The primary key columns that are not already sorted on are added at the end,
+// so the order is always deterministic and a cursor points to a single row.
+func orderKeys(query Query, pkColNames []string) []SortKey {
+	keys := append([]SortKey{}, query.Sort...)
+
+	for _, col := range pkColNames {
+		found := false
+		for _, key := range keys {
+			if key.Column == col {
+				found = true
+				break
+			}
+		}
+		if !found {
+			keys = append(keys, SortKey{Column: col})
+		}
+	}
+	return keys
+}
+
+// encodeCursor returns the cursor that points after the given row,
+// for a list ordered by the given keys
+func encodeCursor(table interface{}, keys []SortKey) (string, error) {
+	var cursor Cursor
+
+	for _, key := range keys {
+		value, ok := columnValue(table, key.Column)
+		if !ok {
+			return "", fmt.Errorf("unknown column %q", key.Column)
+		}
+		cursor.Columns = append(cursor.Columns, key.String())
+		cursor.Values = append(cursor.Values, value)
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a cursor returned by encodeCursor
+func decodeCursor(s string) (*Cursor, error) {
+	var cursor Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	// Keep the numbers as they are written, so large ids are not rounded to a float
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil || len(cursor.Columns) != len(cursor.Values) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	// The values are bound to placeholders, so a forged object or array is not passed to the driver
+	for _, value := range cursor.Values {
+		switch value.(type) {
+		case string, json.Number, bool, nil:
+		default:
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	}
+	return &cursor, nil
+}
+
+// checkCursor returns an error if the cursor was not taken in the order of the given keys
+func checkCursor(cursor *Cursor, keys []SortKey) error {
+	if len(cursor.Columns) != len(keys) {
+		return fmt.Errorf("the cursor does not match the sort order")
+	}
+	for i, key := range keys {
+		if cursor.Columns[i] != key.String() {
+			return fmt.Errorf("the cursor does not match the sort order")
+		}
+	}
+	return nil
+}
+
+// columnValue returns the value of the field with the given "db" tag,
+// in the form the database driver receives it
+//
+// The value of a driver.Valuer is taken, so a timestamp keeps its fractional
+// seconds when the cursor is written to JSON.
+func columnValue(table interface{}, column string) (any, bool) {
+	field, ok := metaOf(table).Fields[column]
+	if !ok {
+		return nil, false
+	}
+
+	value := reflect.ValueOf(table).FieldByIndex(field.Index).Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		driverValue, err := valuer.Value()
+		if err != nil {
+			return nil, false
+		}
+		return driverValue, true
+	}
+	return value, true
+}
+
+// pickFields returns the given columns of a struct as a map from their JSON name to
+// their value, so only the requested fields are written into the response.
+func pickFields(table interface{}, columns []string) map[string]any {
+	meta := metaOf(table)
+	reflectValue := reflect.ValueOf(table)
+
+	fields := make(map[string]any, len(columns))
+
+	// Use the JSON name of the field, the same as when the whole struct is encoded
+	for name, col := range meta.JSONColumns {
+		if inColumns(col, columns) {
+			fields[name] = reflectValue.FieldByIndex(meta.Fields[col].Index).Interface()
+		}
+	}
+	return fields
+}
+
+// pickAllFields returns the items with only the given columns, see pickFields.
+// If no columns are given, the items are returned as they are.
+func pickAllFields[T any](items []T, columns []string) any {
+	if len(columns) == 0 {
+		return items
+	}
+
+	picked := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		picked = append(picked, pickFields(item, columns))
+	}
+	return picked
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// startRenewals starts the background worker that renews the subscriptions that are due,
+// once when the server starts and then every renewInterval
+func startRenewals() {
+	go func() {
+		ticker := time.NewTicker(renewInterval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			renewed, err := renewSubscriptions(renewBatch)
+			if err != nil {
+				log.Println("Error renewing subscriptions:", err.Error())
+			}
+			if renewed > 0 {
+				log.Println("Renewed", renewed, "subscriptions")
+			}
+		}
+	}()
+}
+
+// renewSubscriptions tries to renew up to limit subscriptions that are due, see renewNext
+// It returns how many subscriptions were renewed.
+//
+// A subscription that fails to renew, such as because its payment cannot be inserted,
+// is logged and skipped for the rest of the run, so it does not hold up the others.
+// It is tried again on the next run.
+func renewSubscriptions(limit int) (int, error) {
+	renewed := 0
+	failed := []int64{} // The subscriptions that failed to renew in this run, not nil as NULL would match no row
+
+	for attempts := 0; attempts < limit; attempts++ {
+		id, ok, err := renewNext(failed)
+		if err != nil && id == 0 {
+			// The error is not about a subscription, such as a lost connection, so stop the run
+			return renewed, err
+		} else if err != nil {
+			log.Println("Error renewing subscription", id, "skipping it:", err.Error())
+			failed = append(failed, int64(id))
+			continue
+		}
+		if !ok {
+			break
+		}
+		renewed++
+	}
+	return renewed, nil
+}
+
+// dueSubscriptionSql selects a subscription that is due for renewal: it has ended, it renews
+// automatically, its membership type is not a lifetime plan, its member is not deleted,
+// it has not been renewed yet, and it is not one of the subscriptions in $2.
+//
+// The row is locked until the renewal is committed, and the rows locked by another
+// instance are skipped, so two instances never renew the same subscription at once.
+const dueSubscriptionSql = `SELECT s.subscription_id, s.member_id, s.type_id, s.end_date, t.duration, t.fee
+FROM subscriptions s
+JOIN membershiptypes t ON t.type_id = s.type_id
+JOIN members m ON m.member_id = s.member_id
+WHERE s.auto_renew AND s.end_date <= $1 AND t.duration IS NOT NULL AND m.deleted_at IS NULL
+  AND s.subscription_id <> ALL($2)
+  AND NOT EXISTS (SELECT 1 FROM subscriptions r WHERE r.renewed_from = s.subscription_id)
+ORDER BY s.end_date, s.subscription_id
+LIMIT 1
+FOR UPDATE OF s SKIP LOCKED`
+
+// renewSubscriptionSql inserts the subscription that follows a renewed subscription.
+// renewed_from is unique, so if the subscription has already been renewed nothing is inserted.
+const renewSubscriptionSql = `INSERT INTO subscriptions (member_id, type_id, start_date, end_date, auto_renew, renewed_from)
+VALUES ($1, $2, $3, $4, TRUE, $5)
+ON CONFLICT (renewed_from) DO NOTHING
+RETURNING subscription_id`
+
+// renewNext renews the next subscription that is due, in a single transaction
+//
+// The follow-on subscription starts when the subscription ends and lasts the duration of
+// its membership type, and a pending payment of the fee of the membership type is recorded
+// for the member. Both are committed together, so a renewal is never half done, and the
+// unique renewed_from column makes a renewal that runs twice, such as after a restart,
+// insert nothing the second time.
+//
+// A subscription that lapsed for a whole period or more, such as while the server was down,
+// is renewed once from now instead: the missed periods are not back-billed.
+//
+// The subscriptions in skip are not renewed. It returns the ID of the subscription it tried
+// to renew, also with an error, which is 0 if the error is not about a subscription,
+// and false if no subscription is due.
+func renewNext(skip []int64) (int, bool, error) {
+	var due Subscription
+	var membershipType MembershipType
+
+	now := timestamp{time.Now()}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	// Lock the next subscription that is due
+	err = tx.QueryRow(dueSubscriptionSql, now, pq.Array(skip)).Scan(
+		&due.SubscriptionID, &due.MemberID, &due.TypeID, &due.EndDate, &membershipType.Duration, &membershipType.Fee)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		// The columns are scanned in order, so the ID is set if a later column, such as the fee, failed
+		return due.SubscriptionID, false, err
+	}
+
+	// The follow-on subscription starts when the renewed one ends, so the periods follow each other,
+	// unless a whole period has passed since then: it starts now and the missed periods are not charged
+	start := due.EndDate.timestamp
+	if end := membershipType.endDate(start); !end.After(now.Time) {
+		start = now
+	}
+	end := membershipType.endDate(start)
+
+	log.Println("Renewing subscription:", due.SubscriptionID)
+
+	var renewalID int
+	err = tx.QueryRow(renewSubscriptionSql, due.MemberID, due.TypeID, start, end, due.SubscriptionID).Scan(&renewalID)
+	if err == sql.ErrNoRows {
+		// The subscription has already been renewed, there is nothing to charge
+		return due.SubscriptionID, true, tx.Commit()
+	} else if err != nil {
+		return due.SubscriptionID, false, err
+	}
+
+	// Record the fee of the new period as a pending payment, due when it starts
+	payment := PaymentRecord{
+		MemberID:    due.MemberID,
+		Amount:      membershipType.Fee,
+		PaymentDate: start,
+		Status:      nullString{sql.NullString{String: "pending", Valid: true}},
+	}
+	sqlScript, args, err := updateOrInsertSql(payment, paymentRepo.tableName, "insert")
+	if err != nil {
+		return due.SubscriptionID, false, err
+	}
+	if _, err := paymentRepo.queryRow(tx, sqlScript, args); err != nil {
+		return due.SubscriptionID, false, err
+	}
+
+	return due.SubscriptionID, true, tx.Commit()
+}
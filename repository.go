@@ -0,0 +1,425 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Repository reads and writes the rows of a single table as structs of type T
+//
+// T is a struct tagged with "db" and "pk". The SQL is generated from the struct tags
+// by the builders in sql.go, and the rows are scanned into the fields with the
+// matching "db" tag, so any tagged struct can be stored without writing table
+// specific queries. T needs no methods: there is no Fields() or other scan method
+// to keep in the order of the columns, see scanTargets.
+//
+// Example:
+//
+//	memberRepo := NewRepository[Member]("members")
+type Repository[T any] struct {
+	tableName string
+}
+
+// NewRepository creates a Repository for the struct T stored in the given table
+func NewRepository[T any](tableName string) *Repository[T] {
+	return &Repository[T]{tableName: tableName}
+}
+
+// Get retrieves the row with the given primary key
+//
+// If fields are given, only those columns are selected and the other fields are left empty.
+// It returns sql.ErrNoRows if the row does not exist.
+func (r *Repository[T]) Get(key Key, fields ...string) (T, error) {
+	var item T
+
+	items, err := r.List(Query{Fields: fields}, key)
+	if err != nil {
+		return item, err
+	}
+	if len(items) == 0 {
+		return item, sql.ErrNoRows
+	}
+	return items[0], nil
+}
+
+// List retrieves the rows that match the query and have one of the given primary keys
+//
+// If no primary key is provided, it retrieves all rows of the table that match the query.
+func (r *Repository[T]) List(query Query, keys ...Key) ([]T, error) {
+	var items []T
+	var item T
+
+	// Create the SELECT SQL statement
+	sqlQuery, args, err := selectSql(item, r.tableName, query, keys...)
+	if err != nil {
+		return items, err
+	}
+
+	// Log the SQL query being executed
+	log.Println("Executing SQL query:", sqlQuery, args)
+
+	// Execute the SQL query
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return items, err
+	}
+	defer rows.Close() // Close the rows result set when finished
+
+	return scanRows[T](rows)
+}
+
+// Page retrieves a page of the rows that match the query
+//
+// The page holds at most query.Limit rows, continuing after query.Cursor.
+// It returns the cursor of the next page, or an empty string if this is the last page.
+func (r *Repository[T]) Page(query Query) ([]T, string, error) {
+	var item T
+
+	if query.Limit <= 0 {
+		query.Limit = pageLimit
+	}
+	limit := query.Limit
+
+	// Select one more row than the limit, to know if there is a next page
+	query.Limit = limit + 1
+	items, err := r.List(query)
+	if err != nil {
+		return items, "", err
+	}
+
+	// If there is no row after this page, there is no next page
+	if len(items) <= limit {
+		if items == nil {
+			items = []T{}
+		}
+		return items, "", nil
+	}
+	items = items[:limit]
+
+	// The next page starts after the last row of this page
+	_, pkColNames := getColumns(item)
+	cursor, err := encodeCursor(items[limit-1], orderKeys(query, pkColNames))
+	return items, cursor, err
+}
+
+// Create inserts the given item as a new row
+//
+// It returns the row as it was stored, with the values set by the database.
+func (r *Repository[T]) Create(item T) (T, error) {
+	// Create the INSERT SQL statement
+	sqlScript, args, err := updateOrInsertSql(item, r.tableName, "insert")
+	if err != nil {
+		return item, err
+	}
+
+	return r.queryRow(db, sqlScript, args)
+}
+
+// BatchError is the error of a single item of a batch, Index is its position in the batch
+type BatchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// maxParams is the largest number of parameters Postgres accepts in a single statement
+const maxParams = 65535
+
+// CreateMany inserts the given items in a single transaction
+//
+// If atomic is true, either all items are inserted or none is. The items are inserted
+// with multi-row INSERT statements, as many rows per statement as the parameter limit
+// allows, and the first error rolls back the whole batch and is returned.
+//
+// Otherwise the batch is best-effort: each item is inserted on its own savepoint,
+// an item that fails is rolled back and its error returned as a BatchError,
+// and the other items are still inserted.
+//
+// It returns the inserted rows as they were stored, in the order of the items.
+func (r *Repository[T]) CreateMany(items []T, atomic bool) ([]T, []BatchError, error) {
+	var created []T
+	var batchErrors []BatchError
+
+	// Start the transaction, it is rolled back unless it is committed
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if atomic {
+		// Insert as many rows per statement as the parameter limit allows
+		writeCols, _, _, _ := writeColumns(*new(T))
+		chunkSize := maxParams / max(len(writeCols), 1)
+
+		for start := 0; start < len(items); start += chunkSize {
+			end := min(start+chunkSize, len(items))
+
+			tables := make([]interface{}, 0, end-start)
+			for _, item := range items[start:end] {
+				tables = append(tables, item)
+			}
+
+			// Create the multi-row INSERT SQL statement
+			sqlScript, args, err := insertManySql(r.tableName, tables...)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Log the SQL statement being executed, without the values of every row
+			log.Println("Executing SQL: inserting", end-start, "rows into", r.tableName)
+
+			rows, err := tx.Query(sqlScript, args...)
+			if err != nil {
+				return nil, nil, err
+			}
+			chunk, err := scanRows[T](rows)
+			rows.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+			created = append(created, chunk...)
+		}
+	} else {
+		for i, item := range items {
+			// Create the INSERT SQL statement
+			sqlScript, args, err := updateOrInsertSql(item, r.tableName, "insert")
+			if err != nil {
+				batchErrors = append(batchErrors, BatchError{Index: i, Error: err.Error()})
+				continue
+			}
+
+			// Keep a savepoint, so a failed insert does not abort the transaction
+			if _, err := tx.Exec("SAVEPOINT batch_item"); err != nil {
+				return nil, nil, err
+			}
+
+			row, err := r.queryRow(tx, sqlScript, args)
+			if err != nil {
+				// If the item fails, undo it and carry on with the next item
+				if _, err := tx.Exec("ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+					return nil, nil, err
+				}
+				batchErrors = append(batchErrors, BatchError{Index: i, Error: err.Error()})
+				continue
+			}
+			if _, err := tx.Exec("RELEASE SAVEPOINT batch_item"); err != nil {
+				return nil, nil, err
+			}
+			created = append(created, row)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	if created == nil {
+		created = []T{}
+	}
+	return created, batchErrors, nil
+}
+
+// Update writes the given item over the row with the same primary key
+//
+// It returns the row as it was stored, with the values set by the database.
+// It returns sql.ErrNoRows if the row does not exist.
+func (r *Repository[T]) Update(item T) (T, error) {
+	// Create the UPDATE SQL statement
+	sqlScript, args, err := updateOrInsertSql(item, r.tableName, "update")
+	if err != nil {
+		return item, err
+	}
+
+	return r.queryRow(db, sqlScript, args)
+}
+
+// Upsert inserts the given item, or updates the row that has the same values in the
+// conflict columns, which have to be the primary key or a column with a "unique" tag.
+//
+// It returns the row as it was stored, and whether it was inserted or updated.
+// It returns sql.ErrNoRows if the conflicting row is soft deleted.
+func (r *Repository[T]) Upsert(item T, conflictColumns ...string) (T, bool, error) {
+	var inserted bool
+
+	// Create the INSERT ... ON CONFLICT SQL statement
+	sqlScript, args, err := updateOrInsertSql(item, r.tableName, "upsert", conflictColumns...)
+	if err != nil {
+		return item, false, err
+	}
+
+	// Log the SQL statement being executed
+	log.Println("Executing SQL:", sqlScript, args)
+
+	rows, err := db.Query(sqlScript, args...)
+	if err != nil {
+		return item, false, err
+	}
+	defer rows.Close() // Close the rows result set when finished
+
+	// Scan the row, and whether it was inserted
+	var row T
+	err = scanRow(rows, &row, map[string]any{"inserted": &inserted})
+	return row, inserted, err
+}
+
+// Patch applies a JSON merge patch to the row with the given primary key, see patchSql
+//
+// It returns the row as it was stored, with the values set by the database.
+// It returns sql.ErrNoRows if the row does not exist.
+func (r *Repository[T]) Patch(key Key, patch map[string]json.RawMessage) (T, error) {
+	var item T
+
+	// Create the UPDATE SQL statement
+	sqlScript, args, err := patchSql(item, r.tableName, key, patch)
+	if err != nil {
+		return item, err
+	}
+
+	// If the patch does not change any column, return the row as it is
+	if sqlScript == "" {
+		return r.Get(key)
+	}
+
+	return r.queryRow(db, sqlScript, args)
+}
+
+// Restore brings back the soft deleted row with the given primary key, see restoreSql
+//
+// It returns the row as it was stored.
+// It returns sql.ErrNoRows if the row does not exist or is not deleted.
+func (r *Repository[T]) Restore(key Key) (T, error) {
+	var item T
+
+	// Create the UPDATE SQL statement
+	sqlScript, args, err := restoreSql(item, r.tableName, key)
+	if err != nil {
+		return item, err
+	}
+
+	return r.queryRow(db, sqlScript, args)
+}
+
+// querier runs a query on the database or in a transaction, it is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// queryRow executes a statement that returns a single row, and scans the row into a new item
+//
+// It returns sql.ErrNoRows if the statement does not return a row.
+func (r *Repository[T]) queryRow(q querier, sqlScript string, args []any) (T, error) {
+	var item T
+
+	// Log the SQL statement being executed
+	log.Println("Executing SQL:", sqlScript, args)
+
+	rows, err := q.Query(sqlScript, args...)
+	if err != nil {
+		return item, err
+	}
+	defer rows.Close() // Close the rows result set when finished
+
+	err = scanRow(rows, &item, nil)
+	return item, err
+}
+
+// Delete removes the rows with the given primary keys
+// If T has a "softdelete" column, the rows are marked as deleted instead, see deleteSql.
+//
+// It returns the keys of the rows that were deleted, and the keys that did not exist.
+func (r *Repository[T]) Delete(keys ...Key) ([]Key, []Key, error) {
+	var item T
+	var deleted []Key  // The keys of the deleted rows
+	var notFound []Key // The keys that did not match a row
+
+	// Create the DELETE SQL statement
+	sqlScript, args, err := deleteSql(item, r.tableName, keys...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Log the SQL statement being executed
+	log.Println("Executing SQL:", sqlScript, args)
+
+	rows, err := db.Query(sqlScript, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close() // Close the rows result set when finished
+
+	// Scan the key of every deleted row into the primary key fields of an item,
+	// so the values have the types of the fields rather than the types of the driver
+	_, pkColNames := getColumns(item)
+	for rows.Next() {
+		var row T
+
+		targets, err := scanTargets(&row, pkColNames, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, nil, err
+		}
+
+		key := make(Key, len(pkColNames))
+		for i, col := range pkColNames {
+			key[i], _ = columnValue(row, col)
+		}
+		deleted = append(deleted, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// The keys that were not returned did not exist
+	for _, key := range keys {
+		if !containsKey(deleted, key) {
+			notFound = append(notFound, key)
+		}
+	}
+	return deleted, notFound, nil
+}
+
+// containsKey checks if a key is in the given keys, comparing their values with sameKeyValue
+func containsKey(keys []Key, key Key) bool {
+	for _, k := range keys {
+		if len(k) != len(key) {
+			continue
+		}
+		same := true
+		for i := range k {
+			same = same && sameKeyValue(k[i], key[i])
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// sameKeyValue checks if two key values are equal once they are converted the way
+// the driver receives them, so an int matches an int64, a []byte matches a string,
+// a date matches its DATE text and two times match if they are the same instant
+func sameKeyValue(a, b any) bool {
+	a, errA := driver.DefaultParameterConverter.ConvertValue(a)
+	b, errB := driver.DefaultParameterConverter.ConvertValue(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	if bytes, ok := a.([]byte); ok {
+		a = string(bytes)
+	}
+	if bytes, ok := b.([]byte); ok {
+		b = string(bytes)
+	}
+
+	timeA, okA := a.(time.Time)
+	timeB, okB := b.(time.Time)
+	if okA && okB {
+		return timeA.Equal(timeB)
+	}
+	return a == b
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// scanTargets returns the pointers to the fields of the struct that ptr points to,
+// in the order of the given columns, to be passed to rows.Scan
+//
+// The fields are matched to the columns by their "db" tag. A column that is not
+// a field is scanned into the target with the same name in extra, if there is one.
+func scanTargets(ptr interface{}, columns []string, extra map[string]any) ([]any, error) {
+	reflectValue := reflect.ValueOf(ptr).Elem()
+	fields := metaOf(ptr).Fields
+
+	targets := make([]any, 0, len(columns))
+
+	for _, col := range columns {
+		if field, ok := fields[col]; ok {
+			targets = append(targets, reflectValue.FieldByIndex(field.Index).Addr().Interface())
+		} else if target, ok := extra[col]; ok {
+			targets = append(targets, target)
+		} else {
+			return nil, fmt.Errorf("no field of %s has the column %q", reflectValue.Type().Name(), col)
+		}
+	}
+	return targets, nil
+}
+
+// scanRows scans every row into a new item, matching the columns of the result to the fields
+// The columns that are not in the result are left empty.
+func scanRows[T any](rows *sql.Rows) ([]T, error) {
+	var items []T
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var item T
+
+		targets, err := scanTargets(&item, columns, nil)
+		if err != nil {
+			return items, err
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// scanRow scans the first row into the struct that ptr points to, see scanTargets
+//
+// It returns sql.ErrNoRows if there is no row.
+func scanRow(rows *sql.Rows, ptr interface{}, extra map[string]any) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	targets, err := scanTargets(ptr, columns, extra)
+	if err != nil {
+		return err
+	}
+	return rows.Scan(targets...)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// schemaTable is a table whose schema is described by the tags of a struct
+type schemaTable struct {
+	Name  string
+	Table interface{}
+}
+
+// schemaTables are the tables the DDL generator and the drift checker work on
+var schemaTables = []schemaTable{
+	{Name: "members", Table: Member{}},
+	{Name: "membershiptypes", Table: MembershipType{}},
+	{Name: "subscriptions", Table: Subscription{}},
+	{Name: "paymentrecords", Table: PaymentRecord{}},
+}
+
+// Drift is a difference between a struct and the table in the database
+//
+// Kind is "missing" for a column that is in the struct but not in the table,
+// "extra" for a column that is in the table but not in the struct,
+// "type" for a column with a different type and "nullable" for a column
+// that allows NULL in one and not in the other.
+type Drift struct {
+	Table    string
+	Column   string
+	Kind     string
+	Expected string
+	Actual   string
+}
+
+// String returns the drift as a line of a report
+func (d Drift) String() string {
+	switch d.Kind {
+	case "missing":
+		return fmt.Sprintf("%s.%s: missing in the database, expected %s", d.Table, d.Column, d.Expected)
+	case "extra":
+		return fmt.Sprintf("%s.%s: not in the struct, the database has %s", d.Table, d.Column, d.Actual)
+	default:
+		return fmt.Sprintf("%s.%s: %s is %s, expected %s", d.Table, d.Column, d.Kind, d.Actual, d.Expected)
+	}
+}
+
+// createTableSql generates the CREATE TABLE statement of a struct
+//
+// The columns are read from the tags of the struct: "db" is the column name,
+// "type" the SQL type, "pk" marks the primary key, "nullable" allows NULL,
+// "unique" adds a unique constraint and "default" sets the default value.
+//
+//	CREATE TABLE members
+//	(
+//	    member_id SERIAL PRIMARY KEY,
+//	    email VARCHAR(255) NOT NULL UNIQUE,
+//	    ...
+//	);
+func createTableSql(table interface{}, tableName string) (string, error) {
+	reflectType := reflect.TypeOf(table)
+
+	var lines []string                 // The column definitions and constraints
+	_, pkColNames := getColumns(table) // The primary key columns
+	uniques := metaOf(table).Unique    // The columns with a unique constraint
+
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+
+		colName, ok := field.Tag.Lookup("db")
+		if !ok {
+			continue
+		}
+
+		sqlType, ok := field.Tag.Lookup("type")
+		if !ok {
+			return "", fmt.Errorf("field %s.%s has no type tag", reflectType.Name(), field.Name)
+		}
+
+		line := colName + " " + sqlType
+
+		// A single primary key column is declared inline, it is NOT NULL by itself
+		if len(pkColNames) == 1 && colName == pkColNames[0] {
+			line += " PRIMARY KEY"
+		} else if !isNullable(field) {
+			line += " NOT NULL"
+		}
+
+		if inColumns(colName, uniques) {
+			line += " UNIQUE"
+		}
+
+		if def, ok := field.Tag.Lookup("default"); ok {
+			line += " DEFAULT " + def
+		}
+
+		lines = append(lines, line)
+	}
+
+	// A composite primary key is declared as a table constraint
+	if len(pkColNames) > 1 {
+		lines = append(lines, "PRIMARY KEY ("+strings.Join(pkColNames, ", ")+")")
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s\n(\n    %s\n);", tableName, strings.Join(lines, ",\n    ")), nil
+}
+
+// checkDrift compares a struct with the columns of its table in information_schema.columns
+// and returns the columns that are missing, extra, or have a different type or nullability.
+func checkDrift(table interface{}, tableName string) ([]Drift, error) {
+	var drifts []Drift
+
+	reflectType := reflect.TypeOf(table)
+
+	// Get the columns of the table from the database
+	columns, err := loadColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// If the table does not exist, report it as a whole
+	if len(columns) == 0 {
+		return []Drift{{Table: tableName, Column: "*", Kind: "missing", Expected: "the table"}}, nil
+	}
+
+	actual := make(map[string]dbColumn, len(columns))
+	for _, col := range columns {
+		actual[col.Name] = col
+	}
+
+	_, pkColNames := getColumns(table)
+	var expectedOrder []string // The columns of the struct
+
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+
+		colName, ok := field.Tag.Lookup("db")
+		if !ok {
+			continue
+		}
+		expectedOrder = append(expectedOrder, colName)
+
+		expectedType := normalizeType(field.Tag.Get("type"))
+		expectedNullable := isNullable(field) && !inColumns(colName, pkColNames)
+
+		col, exists := actual[colName]
+		if !exists {
+			drifts = append(drifts, Drift{Table: tableName, Column: colName, Kind: "missing", Expected: expectedType})
+			continue
+		}
+
+		if expectedType != "" && col.SQLType != expectedType {
+			drifts = append(drifts, Drift{Table: tableName, Column: colName, Kind: "type", Expected: expectedType, Actual: col.SQLType})
+		}
+
+		if col.Nullable != expectedNullable {
+			drifts = append(drifts, Drift{Table: tableName, Column: colName, Kind: "nullable", Expected: nullability(expectedNullable), Actual: nullability(col.Nullable)})
+		}
+	}
+
+	for _, col := range columns {
+		if !inColumns(col.Name, expectedOrder) {
+			drifts = append(drifts, Drift{Table: tableName, Column: col.Name, Kind: "extra", Actual: col.SQLType})
+		}
+	}
+	return drifts, nil
+}
+
+// dbColumn is a column of a table as it is described by information_schema
+type dbColumn struct {
+	Name     string
+	SQLType  string // The canonical type, see normalizeType
+	Nullable bool
+	Default  string // The default value, empty if there is none
+	PK       bool   // Whether the column is part of the primary key
+	Unique   bool   // Whether the column has a unique constraint of its own
+}
+
+// loadColumns returns the columns of a table in the database, in the order of the table
+// It returns no column if the table does not exist.
+func loadColumns(tableName string) ([]dbColumn, error) {
+	var columns []dbColumn
+
+	// The table names are not quoted in the DDL, so Postgres stores them in lower case
+	tableName = strings.ToLower(tableName)
+
+	rows, err := db.Query(`SELECT c.column_name, c.data_type, c.is_nullable, c.character_maximum_length,
+       c.numeric_precision, c.numeric_scale, c.datetime_precision, COALESCE(c.column_default, ''),
+       EXISTS (SELECT 1
+               FROM information_schema.table_constraints tc
+               JOIN information_schema.key_column_usage k
+                 ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema
+               WHERE tc.table_schema = c.table_schema AND tc.table_name = c.table_name
+                 AND tc.constraint_type = 'PRIMARY KEY' AND k.column_name = c.column_name),
+       EXISTS (SELECT 1
+               FROM information_schema.table_constraints tc
+               JOIN information_schema.key_column_usage k
+                 ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema
+               WHERE tc.table_schema = c.table_schema AND tc.table_name = c.table_name
+                 AND tc.constraint_type = 'UNIQUE' AND k.column_name = c.column_name
+                 AND (SELECT count(*) FROM information_schema.key_column_usage k2
+                      WHERE k2.constraint_name = tc.constraint_name AND k2.table_schema = tc.table_schema) = 1)
+FROM information_schema.columns c
+WHERE c.table_schema = current_schema() AND c.table_name = $1
+ORDER BY c.ordinal_position`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var col dbColumn
+		var dataType, isNullable string
+		var length, precision, scale, datetimePrecision *int
+		if err := rows.Scan(&col.Name, &dataType, &isNullable, &length, &precision, &scale, &datetimePrecision, &col.Default, &col.PK, &col.Unique); err != nil {
+			return nil, err
+		}
+		col.SQLType = infoSchemaType(dataType, length, precision, scale, datetimePrecision)
+		col.Nullable = isNullable == "YES"
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// isNullable checks if the column of a field allows NULL, from its "nullable" tag
+func isNullable(field reflect.StructField) bool {
+	return field.Tag.Get("nullable") == "true"
+}
+
+// nullability returns how a nullability is written in a drift report
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// sqlTypePattern splits a SQL type into its name and its arguments, such as NUMERIC(10, 2)
+var sqlTypePattern = regexp.MustCompile(`^\s*([A-Za-z ]+?)\s*(?:\(\s*([0-9 ,]+)\s*\))?\s*$`)
+
+// normalizeType returns the canonical form of a SQL type, so that the type in a tag
+// can be compared with the type in information_schema: SERIAL is INTEGER,
+// CHARACTER VARYING is VARCHAR, TIMESTAMP has its default precision of 6, and so on.
+func normalizeType(sqlType string) string {
+	match := sqlTypePattern.FindStringSubmatch(sqlType)
+	if match == nil {
+		return strings.ToUpper(strings.TrimSpace(sqlType))
+	}
+	name := strings.ToUpper(match[1])
+	args := strings.ReplaceAll(match[2], " ", "")
+
+	switch name {
+	case "SERIAL", "INT", "INT4", "INTEGER":
+		return "INTEGER"
+	case "BIGSERIAL", "INT8", "BIGINT":
+		return "BIGINT"
+	case "SMALLSERIAL", "INT2", "SMALLINT":
+		return "SMALLINT"
+	case "BOOL", "BOOLEAN":
+		return "BOOLEAN"
+	case "VARCHAR", "CHARACTER VARYING":
+		name = "VARCHAR"
+	case "TIMESTAMP", "TIMESTAMP WITHOUT TIME ZONE":
+		name = "TIMESTAMP"
+		if args == "" {
+			args = "6"
+		}
+	case "TIMESTAMPTZ", "TIMESTAMP WITH TIME ZONE":
+		name = "TIMESTAMPTZ"
+		if args == "" {
+			args = "6"
+		}
+	case "DECIMAL", "NUMERIC":
+		name = "NUMERIC"
+	}
+
+	if args == "" {
+		return name
+	}
+	return name + "(" + args + ")"
+}
+
+// infoSchemaType returns the canonical form of a column type read from information_schema.columns
+func infoSchemaType(dataType string, length, precision, scale, datetimePrecision *int) string {
+	switch dataType {
+	case "character varying":
+		if length != nil {
+			return fmt.Sprintf("VARCHAR(%d)", *length)
+		}
+		return "VARCHAR"
+	case "numeric":
+		if precision != nil && scale != nil {
+			return fmt.Sprintf("NUMERIC(%d,%d)", *precision, *scale)
+		}
+		return "NUMERIC"
+	case "timestamp without time zone", "timestamp with time zone":
+		name := "TIMESTAMP"
+		if dataType == "timestamp with time zone" {
+			name = "TIMESTAMPTZ"
+		}
+		if datetimePrecision != nil {
+			return fmt.Sprintf("%s(%d)", name, *datetimePrecision)
+		}
+		return name
+	default:
+		return normalizeType(dataType)
+	}
+}
@@ -2,181 +2,708 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-// selectSql generates a SELECT SQL query based on the given table name, and optionally primary keys
-func selectSql(table interface{}, tableName string, id ...int) string {
+// errInvalidInput is wrapped by the errors that are caused by the input of a request,
+// such as an unknown field, so handlers can tell them apart from database errors.
+var errInvalidInput = errors.New("invalid input")
 
-	var idStr []string
+// Key is the primary key of a row, with one value per primary key column
+// in the order the "pk" tags appear in the struct.
+// A table with a single primary key column uses a Key with a single value.
+type Key []any
+
+// selectSql generates a parameterized SELECT SQL query based on the given table name, query and optionally primary keys.
+// The keys and the filter values of the query are not written into the SQL query, they are
+// returned as the ordered args slice that has to be passed to the driver together with the query.
+func selectSql(table interface{}, tableName string, query Query, keys ...Key) (string, []any, error) {
+
+	var args []any          // The values bound to the placeholders
+	var conditions []string // The conditions of the WHERE clause
 	var condition string
-	colNames, pkColName := getColumns(table) // Get the column names and the primary key column name
+	colNames, pkColNames := getColumns(table) // Get the column names and the primary key column names
+
+	if len(keys) > 0 {
+		keyStr, keyArgs, err := keyCondition(pkColNames, keys, args)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, keyStr)
+		args = keyArgs
+	}
+
+	// If the rows are soft deleted, hide the deleted rows unless they are asked for
+	if softDelete := metaOf(table).SoftDelete; softDelete != "" && !query.IncludeDeleted {
+		conditions = append(conditions, softDelete+" IS NULL")
+	}
+
+	for _, filter := range query.Filters {
+		filterStr, filterArgs, err := filterCondition(table, filter, args)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, filterStr)
+		args = filterArgs
+	}
+
+	// The rows are always ordered, with the primary key as the last key
+	order := orderKeys(query, pkColNames)
 
-	for _, value := range id {
-		idStr = append(idStr, strconv.Itoa(value))
+	// If the query continues from a cursor, only select the rows after it
+	if query.Cursor != nil {
+		seekStr, seekArgs, err := seekCondition(colNames, metaOf(table).Nullable, order, query.Cursor, args)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, seekStr)
+		args = seekArgs
 	}
 
-	if len(id) > 0 {
-		condition = " WHERE " + pkColName + " IN (" + strings.Join(idStr, ",") + ")"
+	if len(conditions) > 0 {
+		condition = " WHERE " + strings.Join(conditions, " and ")
 	} else {
 		condition = ""
 	}
 
-	cols_string := strings.Join(colNames, ", ") // Join the column names with commas
+	orderStr, err := orderBy(colNames, order) // The ORDER BY clause
+	if err != nil {
+		return "", nil, err
+	}
+
+	limitStr := "" // The LIMIT clause, only set if the query has a limit
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		limitStr = " LIMIT " + placeholder(len(args))
+	}
+
+	selected, err := selectColumns(table, query) // The columns to select
+	if err != nil {
+		return "", nil, err
+	}
 
-	return fmt.Sprintf("SELECT %s FROM %s", cols_string, tableName) + condition // Return the generated SQL query
+	cols_string := strings.Join(selected, ", ") // Join the column names with commas
+
+	return fmt.Sprintf("SELECT %s FROM %s", cols_string, tableName) + condition + orderStr + limitStr, args, nil // Return the generated SQL query and its args
 }
 
-// deleteSql generates a DELETE SQL query based on the given table name and primary key
-func deleteSql(table interface{}, tableName string, id int) string {
-	_, pkColName := getColumns(table) // Get the primary key column name
-	idStr := strconv.Itoa(id)         // Convert the primary key value to a string
+// selectColumns returns the columns a SELECT query selects for the given query
+//
+// If the query has no fields, all columns are selected. Otherwise only the fields
+// and the columns the rows are ordered by are selected, in the order of the struct,
+// since the order columns are needed to build the cursor of the next page.
+func selectColumns(table interface{}, query Query) ([]string, error) {
+	colNames, pkColNames := getColumns(table) // Get the column names and the primary key column names
+
+	if len(query.Fields) == 0 {
+		return colNames, nil
+	}
+
+	// The fields are written into the query, so they have to be checked
+	for _, field := range query.Fields {
+		if !inColumns(field, colNames) {
+			return nil, fmt.Errorf("unknown column %q", field)
+		}
+	}
+
+	var orderColumns []string // The columns the rows are ordered by
+	for _, key := range orderKeys(query, pkColNames) {
+		orderColumns = append(orderColumns, key.Column)
+	}
 
-	return fmt.Sprintf("DELETE FROM %s WHERE %s = %s", tableName, pkColName, idStr) // Return the generated SQL query
+	var columns []string
+	for _, col := range colNames {
+		if inColumns(col, query.Fields) || inColumns(col, orderColumns) {
+			columns = append(columns, col)
+		}
+	}
+	return columns, nil
 }
 
-// updateOrInsertSql generates an SQL query based on the given method and JSON string
-func updateOrInsertSql(tableName, jsonStr, method string) string {
+// orderBy generates the ORDER BY clause for the given sort keys
+//
+// NULL is sorted after every value, so it comes last in ascending order and first
+// in descending order. This is the default of Postgres, it is written out so the
+// order always matches the seek condition, see seekCondition.
+func orderBy(colNames []string, keys []SortKey) (string, error) {
+
+	var columns []string // The column and direction of each key
 
-	// Skip columns are columns that should be ignored when doing an update or insert
-	updateOrInsertSkipColumns := strings.Split(skipColumns, ",") // Split the string by comma
-	for i, col := range updateOrInsertSkipColumns {
-		updateOrInsertSkipColumns[i] = strings.TrimSpace(col) // Trim leading and trailing whitespace from each part
+	for _, key := range keys {
+
+		// The column is written into the query, so it has to be checked
+		if !inColumns(key.Column, colNames) {
+			return "", fmt.Errorf("unknown column %q", key.Column)
+		}
+
+		if key.Descending {
+			columns = append(columns, key.Column+" DESC NULLS FIRST")
+		} else {
+			columns = append(columns, key.Column+" ASC NULLS LAST")
+		}
 	}
 
-	res := make(map[string]any) // The map to store the JSON data
-	var columns []string        // The columns to be used in the SQL query
-	var member Member           // The member struct to validate the JSON data
+	if len(columns) == 0 {
+		return "", nil
+	}
+	return " ORDER BY " + strings.Join(columns, ", "), nil
+}
 
-	// Schema validation
-	json.Unmarshal([]byte(jsonStr), &member) // Unmarshal the JSON data into the member struct
+// seekCondition generates the WHERE condition that selects the rows after the cursor
+//
+// The cursor values are appended to args, and the placeholders are numbered
+// so that they follow the values already in args. Each row after the cursor
+// is greater (or smaller, when descending) on the first key that differs:
+//
+//	(join_date < $1 or (join_date = $1 and member_id > $2))
+//
+// When all keys have the same direction and none of them can be NULL, this is
+// written as a row comparison, which Postgres can answer from a multi-column index:
+//
+//	(join_date, member_id) > ($1, $2)
+//
+// The nullable columns are compared the way orderBy sorts them, with NULL after
+// every value, so the rows with a NULL in a sort column are paged through too:
+//
+//	((status > $1 or status IS NULL) or (status = $1 and member_id > $2))
+func seekCondition(colNames, nullable []string, keys []SortKey, cursor *Cursor, args []any) (string, []any, error) {
+
+	// The cursor has to be taken in the same order as the query
+	if err := checkCursor(cursor, keys); err != nil {
+		return "", nil, err
+	}
+	for _, key := range keys {
+		if !inColumns(key.Column, colNames) {
+			return "", nil, fmt.Errorf("unknown column %q", key.Column)
+		}
+	}
 
-	data, _ := json.Marshal(member) // Marshal the member struct back into JSON data
-	json.Unmarshal(data, &res)      // Unmarshal the JSON data into the res map
+	var after []string        // The condition that a row is after the cursor on each key
+	var equal []string        // The condition that a row is equal to the cursor on each key
+	var placeholders []string // The placeholder of each cursor value that is not NULL
+	sameDirection := true     // Whether all keys are sorted in the same direction
+	hasNull := false          // Whether a key can be NULL
+
+	for i, key := range keys {
+		sameDirection = sameDirection && key.Descending == keys[0].Descending
+		hasNull = hasNull || inColumns(key.Column, nullable)
+
+		keyAfter, keyEqual, keyArgs := seekKey(key, inColumns(key.Column, nullable), cursor.Values[i], args)
+		after = append(after, keyAfter)
+		equal = append(equal, keyEqual)
+		if len(keyArgs) > len(args) {
+			placeholders = append(placeholders, placeholder(len(keyArgs)))
+		}
+		args = keyArgs
+	}
 
-	primaryKeys := getPK(member) // Get the primary keys of the table
+	// If all keys have the same direction and cannot be NULL, compare the keys as a row
+	if sameDirection && !hasNull && len(placeholders) == len(keys) && len(keys) > 1 {
+		var columns []string
+		for _, key := range keys {
+			columns = append(columns, key.Column)
+		}
+		compare := ">"
+		if keys[0].Descending {
+			compare = "<"
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), compare, strings.Join(placeholders, ", ")), args, nil
+	}
 
-	if method == "update" {
+	// Otherwise the row is after the cursor if the previous keys are equal
+	// and the current key is after the cursor value
+	var alternatives []string
+	for i := range keys {
+		// Nothing is after NULL in ascending order, so there is no alternative on this key
+		if after[i] == "" {
+			continue
+		}
+		parts := append(append([]string{}, equal[:i]...), after[i])
+		if len(parts) == 1 {
+			alternatives = append(alternatives, parts[0])
+		} else {
+			alternatives = append(alternatives, "("+strings.Join(parts, " and ")+")")
+		}
+	}
 
-		var condition []string // The condition to be used in the SQL query
+	if len(alternatives) == 0 {
+		return "FALSE", args, nil
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], args, nil
+	}
+	return "(" + strings.Join(alternatives, " or ") + ")", args, nil
+}
+
+// seekKey returns the condition that a row is after the cursor value on a single key,
+// and the condition that it is equal to it, with NULL sorted after every value.
+// The value is appended to args unless it is NULL.
+//
+// The after condition is empty when no row can be after the value, which is the case
+// for NULL in ascending order.
+func seekKey(key SortKey, nullable bool, value any, args []any) (string, string, []any) {
+
+	// A NULL cursor value is compared with IS NULL, it cannot be bound
+	if value == nil {
+		if key.Descending {
+			return key.Column + " IS NOT NULL", key.Column + " IS NULL", args
+		}
+		return "", key.Column + " IS NULL", args
+	}
 
-		for key, value := range res {
+	args = append(args, value)
+	p := placeholder(len(args))
+	equal := fmt.Sprintf("%s = %s", key.Column, p)
 
-			// If the column is a primary key, add it to the condition
-			if inColumns(key, primaryKeys) {
+	if key.Descending {
+		// The NULLs come first, so they are before every value
+		return fmt.Sprintf("%s < %s", key.Column, p), equal, args
+	}
 
-				// If the value is a number, add it without quotes
-				if isNumeric(value) {
-					condition = append(condition, fmt.Sprintf("%s = %v", key, value))
+	// The NULLs come last, so they are after every value
+	if nullable {
+		return fmt.Sprintf("(%s > %s or %s IS NULL)", key.Column, p, key.Column), equal, args
+	}
+	return fmt.Sprintf("%s > %s", key.Column, p), equal, args
+}
 
-					// If the value is not a number, add it with quotes
-				} else {
-					condition = append(condition, fmt.Sprintf("%s = '%v'", key, value))
-				}
+// filterCondition generates the WHERE condition for a single filter
+//
+// The filter values are appended to args, and the placeholders are numbered
+// so that they follow the values already in args:
+//
+//	status = $1
+//	status IN ($1, $2)
+func filterCondition(table interface{}, filter Filter, args []any) (string, []any, error) {
+
+	// The column and operator are written into the query, so they have to be checked
+	if err := checkFilter(table, filter.Column, filter.Operator); err != nil {
+		return "", nil, err
+	}
+	operator := filterOperators[filter.Operator]
 
-				// If the column is in the skip columns, skip it
-			} else if inColumns(key, updateOrInsertSkipColumns) {
-				continue
+	if len(filter.Values) == 0 {
+		return "", nil, fmt.Errorf("no value for the filter on column %q", filter.Column)
+	}
 
-				// If the column is not a primary key or in the skip columns, add it to the columns to be updated
-			} else {
+	// The IN operator matches any of the values
+	if filter.Operator == "in" {
+		var placeholders []string
+		for _, value := range filter.Values {
+			args = append(args, value)
+			placeholders = append(placeholders, placeholder(len(args)))
+		}
+		return fmt.Sprintf("%s IN (%s)", filter.Column, strings.Join(placeholders, ", ")), args, nil
+	}
+
+	// The other operators compare the column with a single value
+	if len(filter.Values) > 1 {
+		return "", nil, fmt.Errorf("too many values for the filter on column %q", filter.Column)
+	}
+	args = append(args, filter.Values[0])
+
+	return fmt.Sprintf("%s %s %s", filter.Column, operator, placeholder(len(args))), args, nil
+}
+
+// deleteSql generates a parameterized DELETE SQL query based on the given table name and primary keys
+// The query returns the primary key of every deleted row, so the keys that did not exist can be told apart.
+//
+// If the struct has a "softdelete" column, the rows are not deleted but marked as deleted,
+// and the rows that are already deleted are not matched:
+//
+//	UPDATE members SET deleted_at = CURRENT_TIMESTAMP WHERE member_id = $1 and deleted_at IS NULL RETURNING member_id
+func deleteSql(table interface{}, tableName string, keys ...Key) (string, []any, error) {
+	_, pkColNames := getColumns(table)     // Get the primary key column names
+	softDelete := metaOf(table).SoftDelete // The soft delete column, if there is one
+
+	// Without a key the query would delete the whole table
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("%w: no key to delete", errInvalidInput)
+	}
+
+	condition, args, err := keyCondition(pkColNames, keys, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	returningStr := " RETURNING " + strings.Join(pkColNames, ", ") // Return the keys of the deleted rows
+
+	if softDelete != "" {
+		return fmt.Sprintf("UPDATE %s SET %s = CURRENT_TIMESTAMP WHERE %s and %s IS NULL", tableName, softDelete, condition, softDelete) + returningStr, args, nil
+	}
+
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", tableName, condition) + returningStr, args, nil // Return the generated SQL query and its args
+}
+
+// restoreSql generates a parameterized UPDATE SQL query that restores the soft deleted row
+// with the given primary key. It only matches the row if it is deleted, and returns all of its columns:
+//
+//	UPDATE members SET deleted_at = NULL WHERE member_id = $1 and deleted_at IS NOT NULL RETURNING ...
+func restoreSql(table interface{}, tableName string, key Key) (string, []any, error) {
+	_, pkColNames := getColumns(table)     // Get the primary key column names
+	softDelete := metaOf(table).SoftDelete // The soft delete column
+
+	if softDelete == "" {
+		return "", nil, fmt.Errorf("%s has no softdelete column, its rows cannot be restored", tableName)
+	}
+
+	condition, args, err := keyCondition(pkColNames, []Key{key}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s and %s IS NOT NULL", tableName, softDelete, condition, softDelete) + returning(table), args, nil
+}
+
+// keyCondition generates the WHERE condition that matches the rows with the given primary keys
+//
+// The key values are appended to args, and the placeholders are numbered
+// so that they follow the values already in args.
+// A single key column is matched with = or IN, a composite key matches every key column:
+//
+//	member_id = $1
+//	member_id IN ($1, $2)
+//	((member_id = $1 and type_id = $2) or (member_id = $3 and type_id = $4))
+func keyCondition(pkColNames []string, keys []Key, args []any) (string, []any, error) {
+
+	if len(pkColNames) == 0 {
+		return "", nil, fmt.Errorf("the table has no primary key")
+	}
+
+	var conditions []string // The condition for each key
 
-				// If the value is a number, add it without quotes
-				if isNumeric(value) {
-					columns = append(columns, fmt.Sprintf("%s = %v", key, value))
+	for _, key := range keys {
 
-					// If the value is not a number, add it with quotes
-				} else {
-					columns = append(columns, fmt.Sprintf("%s = '%v'", key, value))
-				}
+		// The key must have one value for each primary key column
+		if len(key) != len(pkColNames) {
+			return "", nil, fmt.Errorf("key %v does not match the primary key (%s)", key, strings.Join(pkColNames, ", "))
+		}
+
+		var columns []string // The condition for each key column
+		for i, col := range pkColNames {
+			args = append(args, key[i])
+
+			// A single key column only needs the placeholder for the IN list
+			if len(pkColNames) == 1 {
+				columns = append(columns, placeholder(len(args)))
+			} else {
+				columns = append(columns, fmt.Sprintf("%s = %s", col, placeholder(len(args))))
 			}
 		}
+		conditions = append(conditions, strings.Join(columns, " and "))
+	}
+
+	// If there is a single key column, match the key or all the keys with IN
+	if len(pkColNames) == 1 && len(conditions) == 1 {
+		return pkColNames[0] + " = " + conditions[0], args, nil
+	} else if len(pkColNames) == 1 {
+		return pkColNames[0] + " IN (" + strings.Join(conditions, ", ") + ")", args, nil
+	}
+
+	// If there is a composite key, match each key with all of its columns
+	if len(conditions) == 1 {
+		return conditions[0], args, nil
+	}
+	return "((" + strings.Join(conditions, ") or (") + "))", args, nil
+}
+
+// updateOrInsertSql generates a parameterized SQL query based on the given method and struct.
+// Every value of the struct is bound to a $n placeholder and returned in the args slice,
+// in the same order as the placeholders appear in the query.
+// The query returns all columns of the written row, so the values set by the
+// database, such as the primary key and the timestamps, can be scanned back.
+//
+// The method "upsert" inserts the row, or updates the row that has the same values
+// in the conflict columns. The conflict columns have to be the primary key or a
+// column with a "unique" tag, and the query also returns whether the row was inserted:
+//
+//	INSERT INTO members (...) VALUES (...) ON CONFLICT (email) DO UPDATE SET first_name = EXCLUDED.first_name, ...
+//	RETURNING ..., (xmax = 0) AS inserted
+//
+// When the table has a soft delete column, a conflicting row that is soft deleted is
+// left as it is and the query returns no row.
+func updateOrInsertSql(table interface{}, tableName, method string, conflictColumns ...string) (string, []any, error) {
+
+	// Get the columns to be written, and the primary key columns apart
+	columns, values, keyColumns, keyValues := writeColumns(table)
+
+	if method == "update" {
+
+		var updates []string   // The part of the SQL query that sets the columns to be updated
+		var condition []string // The condition to be used in the SQL query
+		args := values         // The columns are bound first, then the primary keys
+
+		for i, col := range columns {
+			updates = append(updates, fmt.Sprintf("%s = %s", col, placeholder(i+1)))
+		}
+
+		for i, col := range keyColumns {
+			args = append(args, keyValues[i])
+			condition = append(condition, fmt.Sprintf("%s = %s", col, placeholder(len(args))))
+		}
+
+		// A soft deleted row cannot be updated until it is restored
+		if softDelete := metaOf(table).SoftDelete; softDelete != "" {
+			condition = append(condition, softDelete+" IS NULL")
+		}
 
 		tableStr := "UPDATE " + tableName + " SET " // The beginning of the SQL query
 
-		updateStr := strings.Join(columns, ", ") // The part of the SQL query that sets the columns to be updated
+		updateStr := strings.Join(updates, ", ") // The part of the SQL query that sets the columns to be updated
 
 		conditionStr := " WHERE " + strings.Join(condition, " and ") // The part of the SQL query that sets the condition
 
-		return tableStr + updateStr + conditionStr // Return the full SQL query
+		return tableStr + updateStr + conditionStr + returning(table), args, nil // Return the full SQL query and its args
 
 		// If the method is "insert"
 	} else if method == "insert" {
 
-		var values []string // The values to be used in the SQL query
+		var placeholders []string // The placeholders to be used in the SQL query
 
-		for key, value := range res {
+		for i := range columns {
+			placeholders = append(placeholders, placeholder(i+1))
+		}
 
-			// If the column is in the skip columns or a primary key, skip it
-			if inColumns(key, updateOrInsertSkipColumns) || inColumns(key, primaryKeys) {
-				continue
+		tableStr := "INSERT INTO " + tableName + " " // The beginning of the SQL query
 
-				// If the column is not in the skip columns or a primary key, add it to the columns and values
-			} else {
+		insertStr := fmt.Sprintf("(%s) VALUES (%s)", strings.Join(columns, ", "), strings.Join(placeholders, ", ")) // The part of the SQL query that sets the columns and values
+
+		return tableStr + insertStr + returning(table), values, nil // Return the full SQL query and its args
 
-				// If the value is a number, add it without quotes
-				if isNumeric(value) {
-					columns = append(columns, key)
-					values = append(values, fmt.Sprintf("%v", value))
+		// If the method is "upsert"
+	} else if method == "upsert" {
 
-					// If the value is not a number, add it with quotes
-				} else {
-					columns = append(columns, key)
-					values = append(values, fmt.Sprintf("'%v'", value))
-				}
+		// The conflict columns are written into the query, so they have to be checked
+		if err := checkConflictColumns(table, conflictColumns); err != nil {
+			return "", nil, err
+		}
+
+		// If the conflict is on the primary key, the primary key has to be inserted too
+		if len(keyColumns) > 0 && inColumns(keyColumns[0], conflictColumns) {
+			columns = append(keyColumns, columns...)
+			values = append(keyValues, values...)
+		}
+
+		var placeholders []string // The placeholders to be used in the SQL query
+		var updates []string      // The columns to be updated when the row already exists
+
+		for i, col := range columns {
+			placeholders = append(placeholders, placeholder(i+1))
+
+			// The conflict columns already have the inserted values
+			if !inColumns(col, conflictColumns) {
+				updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
 			}
 		}
 
+		// If there is nothing else to update, set a conflict column so the row is still returned
+		if len(updates) == 0 {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", conflictColumns[0], conflictColumns[0]))
+		}
+
 		tableStr := "INSERT INTO " + tableName + " " // The beginning of the SQL query
 
-		insertStr := fmt.Sprintf("(%s) VALUES (%s)", strings.Join(columns, ", "), strings.Join(values, ", ")) // The part of the SQL query that sets the columns and values
+		insertStr := fmt.Sprintf("(%s) VALUES (%s)", strings.Join(columns, ", "), strings.Join(placeholders, ", ")) // The part of the SQL query that sets the columns and values
 
-		return tableStr + insertStr // Return the full SQL query
+		conflictStr := fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflictColumns, ", "), strings.Join(updates, ", ")) // The part of the SQL query that updates the existing row
 
-		// If the method is not "update" or "insert", return an empty string
+		// A soft deleted row is not updated, so no row is returned until it is restored
+		if softDelete := metaOf(table).SoftDelete; softDelete != "" {
+			conflictStr += fmt.Sprintf(" WHERE %s.%s IS NULL", tableName, softDelete)
+		}
+
+		// xmax is only set on a row version that replaced an older one, so it is 0 for an inserted row
+		return tableStr + insertStr + conflictStr + returning(table) + ", (xmax = 0) AS inserted", values, nil // Return the full SQL query and its args
+
+		// If the method is not "update", "insert" or "upsert", return an error
 	} else {
-		return ""
+		return "", nil, fmt.Errorf("unknown method %q", method)
 	}
 }
 
-// isNumeric checks if a given value is numeric
-func isNumeric(value any) bool {
+// insertManySql generates a parameterized INSERT SQL query that inserts all the given
+// structs, which have to be of the same type, with a single multi-row VALUES list:
+//
+//	INSERT INTO members (first_name, ...) VALUES ($1, ...), ($9, ...) RETURNING ...
+//
+// The query returns all columns of the inserted rows, in the order of the structs.
+func insertManySql(tableName string, tables ...interface{}) (string, []any, error) {
+
+	if len(tables) == 0 {
+		return "", nil, fmt.Errorf("no rows to insert")
+	}
+
+	var columns []string // The columns to be used in the SQL query
+	var args []any       // The values bound to the placeholders
+	var rows []string    // The placeholders of each row
+
+	for _, table := range tables {
 
-	switch reflect.TypeOf(value).Kind() {
+		// All rows have to be of the same type, so they have the same columns
+		if reflect.TypeOf(table) != reflect.TypeOf(tables[0]) {
+			return "", nil, fmt.Errorf("cannot insert %T and %T in the same query", tables[0], table)
+		}
 
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
-		return true
-	default:
-		return false
+		var values []any
+		columns, values, _, _ = writeColumns(table)
+
+		var placeholders []string // The placeholders of this row
+		for _, value := range values {
+			args = append(args, value)
+			placeholders = append(placeholders, placeholder(len(args)))
+		}
+		rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
 	}
 
+	tableStr := "INSERT INTO " + tableName + " " // The beginning of the SQL query
+
+	insertStr := fmt.Sprintf("(%s) VALUES %s", strings.Join(columns, ", "), strings.Join(rows, ", ")) // The part of the SQL query that sets the columns and values
+
+	return tableStr + insertStr + returning(tables[0]), args, nil // Return the full SQL query and its args
 }
 
-// getPK returns the primary key column names of a given struct
-func getPK(table interface{}) []string {
-	/*
-		This function takes a struct as input and returns a slice of strings
-		containing the column names of the primary key.
-	*/
+// writeColumns returns the columns of a struct that are written by an update or insert,
+// and their values, in the order of the struct. The primary key columns and their values
+// are returned apart, and the read-only columns are left out.
+func writeColumns(table interface{}) ([]string, []any, []string, []any) {
+	var columns []string    // The columns to be used in the SQL query
+	var values []any        // The values of the columns, in the same order
+	var keyColumns []string // The primary key columns
+	var keyValues []any     // The values of the primary key columns, in the same order
+
+	meta := metaOf(table)
+	reflectValue := reflect.ValueOf(table)
+
+	for _, col := range meta.Columns {
+		// The date, timestamp and null types implement driver.Valuer, so the driver converts them
+		value := reflectValue.FieldByIndex(meta.Fields[col].Index).Interface()
+
+		// If the column is a primary key, keep it apart for the condition
+		if inColumns(col, meta.PKColumns) {
+			keyColumns = append(keyColumns, col)
+			keyValues = append(keyValues, value)
+
+			// If the column is read-only, skip it
+		} else if inColumns(col, meta.ReadOnly) {
+			continue
+
+			// If the column is not a primary key or read-only, add it to the columns
+		} else {
+			columns = append(columns, col)
+			values = append(values, value)
+		}
+	}
+	return columns, values, keyColumns, keyValues
+}
+
+// checkConflictColumns returns an error if the columns are not a unique key of the struct,
+// that is either all of its primary key columns or a single column with a "unique" tag
+func checkConflictColumns(table interface{}, conflictColumns []string) error {
+	_, pkColNames := getColumns(table)
+
+	// The primary key is unique, in whatever order its columns are given
+	if len(conflictColumns) == len(pkColNames) {
+		matches := true
+		for _, col := range conflictColumns {
+			matches = matches && inColumns(col, pkColNames)
+		}
+		if matches {
+			return nil
+		}
+	}
+
+	// A column with a "unique" tag is unique by itself
+	if len(conflictColumns) == 1 && inColumns(conflictColumns[0], metaOf(table).Unique) {
+		return nil
+	}
 
-	reflectType := reflect.TypeOf(table)
-	var pks []string
-	nFields := reflectType.NumField()
+	return fmt.Errorf("%w: (%s) is not a unique key", errInvalidInput, strings.Join(conflictColumns, ", "))
+}
 
-	for i := 0; i < nFields; i++ {
+// patchSql generates a parameterized UPDATE SQL query that applies a JSON merge patch (RFC 7386)
+// to the row with the given primary key.
+//
+// Only the keys present in the patch are updated, and a key set to null sets the column to NULL.
+// The keys are the JSON names of the struct fields, and each value is decoded into the type
+// of its field, so it is checked the same way as the body of a PUT request.
+// Read-only columns are ignored, and the primary key cannot be changed.
+// If the patch does not update any column, an empty query is returned.
+func patchSql(table interface{}, tableName string, key Key, patch map[string]json.RawMessage) (string, []any, error) {
+
+	var updates []string // The part of the SQL query that sets the columns to be updated
+	var args []any       // The values bound to the placeholders
+
+	meta := metaOf(table) // The columns of the struct
+
+	// Sort the keys, so the same patch always gives the same SQL query
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		// Lookup the "pk" tag in the field's struct tags
-		pk, isFound := reflectType.Field(i).Tag.Lookup("pk")
-		if !isFound {
-			// if the tag is not found, move on to the next field
+	for _, name := range names {
+		col, ok := meta.JSONColumns[name]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unknown field %q", errInvalidInput, name)
+		}
+		field := meta.Fields[col]
+
+		// If the column is read-only, skip it
+		if inColumns(col, meta.ReadOnly) {
 			continue
 		}
-		pks = append(pks, pk)
+
+		// The primary key identifies the row, so it cannot be patched
+		if inColumns(col, meta.PKColumns) {
+			return "", nil, fmt.Errorf("%w: field %q cannot be changed", errInvalidInput, name)
+		}
+
+		args = append(args, nil)
+
+		// If the value is not null, decode it into the type of the field
+		if string(patch[name]) != "null" {
+			value := reflect.New(field.Type)
+			if err := json.Unmarshal(patch[name], value.Interface()); err != nil {
+				return "", nil, fmt.Errorf("%w: invalid value for field %q: %v", errInvalidInput, name, err)
+			}
+			args[len(args)-1] = value.Elem().Interface()
+		}
+		updates = append(updates, fmt.Sprintf("%s = %s", col, placeholder(len(args))))
 	}
-	return pks
+
+	// If there is nothing to update, there is no query
+	if len(updates) == 0 {
+		return "", nil, nil
+	}
+
+	condition, args, err := keyCondition(meta.PKColumns, []Key{key}, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// A soft deleted row cannot be patched until it is restored
+	if meta.SoftDelete != "" {
+		condition += " and " + meta.SoftDelete + " IS NULL"
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", tableName, strings.Join(updates, ", "), condition) + returning(table), args, nil
+}
+
+// returning generates the RETURNING clause that returns all columns of the given struct
+func returning(table interface{}) string {
+	colNames, _ := getColumns(table)
+	return " RETURNING " + strings.Join(colNames, ", ")
+}
+
+// placeholder returns the positional parameter marker for the n-th argument of a query
+func placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
 }
 
 // inColumns checks if a given column name is present in a slice of strings
@@ -189,36 +716,12 @@ func inColumns(column string, columns []string) bool {
 	return false
 }
 
-// getColumns returns a slice of column names and the primary key column name
-// of a given struct. The function uses the "db" struct tag to get the column
-// names and the "pk" struct tag to get the primary key column name.
-func getColumns(table interface{}) ([]string, string) {
-	v := reflect.TypeOf(table)
-
-	colNames := make([]string, 0, v.NumField()-1) // make slice to hold column names
-
-	var pkColName string // primary key column name
-	for i := 0; i < v.NumField(); i++ {
-		// Lookup the "db" tag in the field's struct tags
-		colName, columnExist := v.Field(i).Tag.Lookup("db")
-		// Lookup the "pk" tag in the field's struct tags
-		pk, pkExist := v.Field(i).Tag.Lookup("pk")
-
-		// If the "db" tag is not found and the "pk" tag is not found, move on to the next field
-		if !columnExist && !pkExist {
-			continue
-			// If the "db" tag is not found but the "pk" tag is found, set the primary key column name
-		} else if !columnExist && pkExist {
-			pkColName = pk
-			// If the "db" tag is found and the "pk" tag is not found, add the column name to the slice
-		} else if columnExist && !pkExist {
-			colNames = append(colNames, colName)
-			// If the "db" tag is found and the "pk" tag is found, add the column name to the slice
-			// and set the primary key column name
-		} else {
-			colNames = append(colNames, colName)
-			pkColName = pk
-		}
-	}
-	return colNames, pkColName
+// getColumns returns a slice of column names and the primary key column names
+// of a given struct. The column names come from the "db" struct tags and the
+// primary key column names from the "pk" struct tags, in the order they appear
+// in the struct. They are read from the cached metadata of the type, see metaOf,
+// so they must not be modified.
+func getColumns(table interface{}) ([]string, []string) {
+	meta := metaOf(table)
+	return meta.Columns, meta.PKColumns
 }
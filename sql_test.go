@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeekCondition(t *testing.T) {
+	colNames := []string{"member_id", "join_date", "status", "last_name"}
+	nullable := []string{"status"}
+
+	asc := func(col string) SortKey { return SortKey{Column: col} }
+	desc := func(col string) SortKey { return SortKey{Column: col, Descending: true} }
+
+	tests := []struct {
+		name     string
+		keys     []SortKey
+		values   []any
+		args     []any // The args already bound before the cursor
+		want     string
+		wantArgs []any
+	}{
+		{
+			name:     "single key",
+			keys:     []SortKey{asc("member_id")},
+			values:   []any{"7"},
+			want:     "member_id > $1",
+			wantArgs: []any{"7"},
+		},
+		{
+			name:     "row comparison ascending",
+			keys:     []SortKey{asc("join_date"), asc("member_id")},
+			values:   []any{"2024-01-01", "7"},
+			want:     "(join_date, member_id) > ($1, $2)",
+			wantArgs: []any{"2024-01-01", "7"},
+		},
+		{
+			name:     "row comparison descending, after the filter args",
+			keys:     []SortKey{desc("join_date"), desc("member_id")},
+			values:   []any{"2024-01-01", "7"},
+			args:     []any{"active"},
+			want:     "(join_date, member_id) < ($2, $3)",
+			wantArgs: []any{"active", "2024-01-01", "7"},
+		},
+		{
+			name:     "mixed directions",
+			keys:     []SortKey{desc("join_date"), asc("member_id")},
+			values:   []any{"2024-01-01", "7"},
+			want:     "(join_date < $1 or (join_date = $1 and member_id > $2))",
+			wantArgs: []any{"2024-01-01", "7"},
+		},
+		{
+			name:     "nullable ascending key",
+			keys:     []SortKey{asc("status"), asc("member_id")},
+			values:   []any{"active", "7"},
+			want:     "((status > $1 or status IS NULL) or (status = $1 and member_id > $2))",
+			wantArgs: []any{"active", "7"},
+		},
+		{
+			name:     "nullable descending key",
+			keys:     []SortKey{desc("status"), desc("member_id")},
+			values:   []any{"active", "7"},
+			want:     "(status < $1 or (status = $1 and member_id < $2))",
+			wantArgs: []any{"active", "7"},
+		},
+		{
+			name:     "NULL cursor value ascending",
+			keys:     []SortKey{asc("status"), asc("member_id")},
+			values:   []any{nil, "7"},
+			want:     "(status IS NULL and member_id > $1)",
+			wantArgs: []any{"7"},
+		},
+		{
+			name:     "NULL cursor value descending",
+			keys:     []SortKey{desc("status"), asc("member_id")},
+			values:   []any{nil, "7"},
+			want:     "(status IS NOT NULL or (status IS NULL and member_id > $1))",
+			wantArgs: []any{"7"},
+		},
+		{
+			name:     "NULL cursor value on the last key",
+			keys:     []SortKey{asc("status")},
+			values:   []any{nil},
+			want:     "FALSE",
+			wantArgs: nil,
+		},
+	}
+
+	for _, test := range tests {
+		cursor := &Cursor{Values: test.values}
+		for _, key := range test.keys {
+			cursor.Columns = append(cursor.Columns, key.String())
+		}
+
+		got, gotArgs, err := seekCondition(colNames, nullable, test.keys, cursor, test.args)
+		if err != nil {
+			t.Errorf("%s: seekCondition error = %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: seekCondition = %q, want %q", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(gotArgs, test.wantArgs) {
+			t.Errorf("%s: seekCondition args = %v, want %v", test.name, gotArgs, test.wantArgs)
+		}
+	}
+}
+
+func TestSeekConditionInvalidCursor(t *testing.T) {
+	keys := []SortKey{{Column: "join_date"}, {Column: "member_id"}}
+
+	tests := []struct {
+		name   string
+		cursor *Cursor
+	}{
+		{name: "other order", cursor: &Cursor{Columns: []string{"-join_date", "member_id"}, Values: []any{"2024-01-01", "7"}}},
+		{name: "missing key", cursor: &Cursor{Columns: []string{"join_date"}, Values: []any{"2024-01-01"}}},
+	}
+
+	for _, test := range tests {
+		if _, _, err := seekCondition([]string{"join_date", "member_id"}, nil, keys, test.cursor, nil); err == nil {
+			t.Errorf("%s: seekCondition did not reject the cursor", test.name)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// subscriptionRepo reads and writes subscriptions in the Subscriptions table
+var subscriptionRepo = NewRepository[Subscription]("subscriptions")
+
+// getMemberSubscriptionsHandle handles GET requests to /members/{member_id}/subscriptions
+// This function gets the subscriptions of a member that match the filters in the query string,
+// each with its membership type
+func getMemberSubscriptionsHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the member ID from the URL
+	memberID, err := strconv.Atoi(mux.Vars(r)["member_id"])
+	if err != nil {
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Parse the filters from the query string
+	query, err := parseQuery(Subscription{}, r.URL.Query())
+	if err != nil {
+		log.Println("Error parsing query:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid query: " + err.Error()}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The member has to exist, so an unknown member is not an empty list
+	if !checkMemberExists(w, memberID) {
+		return
+	}
+
+	// Only list the subscriptions of the member
+	query.Filters = append(query.Filters, Filter{Column: "member_id", Operator: "eq", Values: []any{memberID}})
+	log.Println("Getting subscriptions with filters:", query.Filters)
+
+	// Get a page of the subscriptions from the database, with their membership types
+	subscriptions, nextCursor, err := subscriptionRepo.Page(query)
+	if err == nil && len(query.Fields) == 0 {
+		err = loadMembershipTypes(subscriptions)
+	}
+	writePage(w, subscriptions, nextCursor, query, err, "subscriptions")
+}
+
+// getSubscriptionHandle handles GET requests to /subscriptions/{subscription_id}
+// This function gets a single subscription with its membership type
+func getSubscriptionHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get the subscription ID from the URL
+	id, err := strconv.Atoi(mux.Vars(r)["subscription_id"])
+	if err != nil {
+		log.Println("Error converting subscription_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid subscription ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	log.Println("Getting subscription with ID:", id)
+
+	// Get the subscription from the database, with its membership type
+	subscription, err := subscriptionRepo.Get(Key{id})
+	if err == nil {
+		subscriptions := []Subscription{subscription}
+		err = loadMembershipTypes(subscriptions)
+		subscription = subscriptions[0]
+	}
+	if err == sql.ErrNoRows {
+		log.Println("Subscription not found:", id)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Subscription not found!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		log.Println("Error getting subscription:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to get subscription!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// createMemberSubscriptionHandle handles POST requests to /members/{member_id}/subscriptions
+// This function subscribes the member to the membership type of type_id in the body,
+// and returns the subscription with its membership type, 201 Created and its Location.
+//
+// The start_date defaults to now, and the end_date to the start_date plus the duration
+// of the membership type, or NULL for a lifetime plan.
+func createMemberSubscriptionHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var subscription Subscription
+
+	// Get the member ID from the URL
+	memberID, err := strconv.Atoi(mux.Vars(r)["member_id"])
+	if err != nil {
+		log.Println("Error converting member_id to int:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Invalid member ID!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Decode the JSON body of the request into the subscription struct
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		log.Println("Error decoding JSON body:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed to decode JSON body!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// The member in the body has to be the member in the URL, if it is given
+	if subscription.MemberID != 0 && subscription.MemberID != memberID {
+		log.Println("ID mismatch:", memberID, "!=", subscription.MemberID)
+		w.WriteHeader(http.StatusBadRequest)
+		response := Response{Message: "Failed! ID mismatch"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	subscription.MemberID = memberID
+	subscription.RenewedFrom = nil // Only the renewal worker renews a subscription
+	subscription.MembershipType = nil
+
+	// The member has to exist
+	if !checkMemberExists(w, memberID) {
+		return
+	}
+
+	// The membership type has to exist, it sets the end of the subscription
+	membershipType, err := membershipTypeRepo.Get(Key{subscription.TypeID})
+	if err == sql.ErrNoRows {
+		log.Println("Membership type not found:", subscription.TypeID)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		response := Response{Message: fmt.Sprintf("Membership type %d does not exist!", subscription.TypeID)}
+		json.NewEncoder(w).Encode(response)
+		return
+	} else if err != nil {
+		log.Println("Error getting membership type:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to insert!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Fill in the dates that are not given
+	if subscription.StartDate.IsZero() {
+		subscription.StartDate = timestamp{time.Now().UTC()}
+	}
+	if !subscription.EndDate.Valid {
+		subscription.EndDate = membershipType.endDate(subscription.StartDate)
+	}
+
+	// Insert the subscription into the database
+	subscription, err = subscriptionRepo.Create(subscription)
+	if err != nil {
+		log.Println("Error inserting subscription:", err.Error())
+		if isForeignKeyViolation(err) {
+			// The member or the membership type was deleted in the meantime
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		response := Response{Message: "Failed to insert!"}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	subscription.MembershipType = &membershipType
+
+	// If there is no error, return the created subscription and where to find it
+	log.Println("Inserted subscription successfully!")
+	w.Header().Set("Location", fmt.Sprintf("/subscriptions/%d", subscription.SubscriptionID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// checkMemberExists checks that the member with the given ID exists, and is not deleted
+// If it does not, it writes a 404 Not Found response and returns false.
+func checkMemberExists(w http.ResponseWriter, memberID int) bool {
+	_, err := memberRepo.Get(Key{memberID}, "member_id")
+	if err == sql.ErrNoRows {
+		log.Println("Member not found:", memberID)
+		w.WriteHeader(http.StatusNotFound)
+		response := Response{Message: "Member not found!"}
+		json.NewEncoder(w).Encode(response)
+		return false
+	} else if err != nil {
+		log.Println("Error getting member:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		response := Response{Message: "Failed to get member!"}
+		json.NewEncoder(w).Encode(response)
+		return false
+	}
+	return true
+}
+
+// loadMembershipTypes sets the membership type of every subscription,
+// with a single query for all of their types
+func loadMembershipTypes(subscriptions []Subscription) error {
+	var keys []Key
+	for _, subscription := range subscriptions {
+		keys = append(keys, Key{subscription.TypeID})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	types, err := membershipTypeRepo.List(Query{}, keys...)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*MembershipType, len(types))
+	for i := range types {
+		byID[types[i].TypeID] = &types[i]
+	}
+	for i := range subscriptions {
+		subscriptions[i].MembershipType = byID[subscriptions[i].TypeID]
+	}
+	return nil
+}